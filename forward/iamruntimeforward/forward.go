@@ -0,0 +1,126 @@
+// Package iamruntimeforward forwards the request credential to downstream services
+// for calls made on behalf of the authenticated subject.
+//
+// The credential set by the iam-runtime middleware, see [iamruntime.ContextCredential],
+// is added as a bearer token to outgoing HTTP requests and gRPC calls made with the request context.
+// Credentials are only forwarded to allowed destinations over secure connections.
+package iamruntimeforward
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+// Option configures credential forwarding.
+type Option func(*config)
+
+// WithAllowedHosts sets the destinations credentials may be forwarded to.
+//
+// A host matches destinations with the same hostname on any port, such as "api.example.com".
+// A host with a port only matches destinations on that port, such as "api.example.com:8443".
+// A host starting with "*." matches all subdomains, such as "*.example.com" matching "api.example.com"
+// but not "example.com". Other wildcards, such as "*", match no destinations.
+//
+// Default is no hosts, credentials are not forwarded.
+func WithAllowedHosts(hosts ...string) Option {
+	return func(c *config) {
+		c.hosts = append(c.hosts, hosts...)
+	}
+}
+
+// WithRequireCredential rejects calls made with a context which does not have a credential.
+// Default is false, calls without a credential are sent without authorization.
+func WithRequireCredential() Option {
+	return func(c *config) {
+		c.requireCredential = true
+	}
+}
+
+// WithInsecure allows credentials to be forwarded over connections without transport security,
+// such as http URLs and gRPC connections with insecure credentials. Use only for local development.
+// Default is false, credentials are only forwarded over https and gRPC connections with transport security.
+func WithInsecure() Option {
+	return func(c *config) {
+		c.insecure = true
+	}
+}
+
+type config struct {
+	hosts             []string
+	requireCredential bool
+	insecure          bool
+}
+
+func newConfig(opts []Option) config {
+	var c config
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// credential returns the raw credential from the context to forward to the destination.
+// If the context has no credential, an empty string is returned unless a credential is required.
+// An error is returned if the destination is not allowed.
+func (c config) credential(ctx context.Context, destination string) (string, error) {
+	credential := iamruntime.ContextCredential(ctx)
+
+	if credential == nil || credential.Raw == "" {
+		if c.requireCredential {
+			return "", iamruntime.ErrTokenNotFound
+		}
+
+		return "", nil
+	}
+
+	if !c.allowed(destination) {
+		return "", fmt.Errorf("%w: %s", iamruntime.ErrForwardingNotAllowed, destination)
+	}
+
+	return credential.Raw, nil
+}
+
+// allowed returns true if the destination, a host with an optional port, matches an allowed host.
+func (c config) allowed(destination string) bool {
+	hostname, port, err := net.SplitHostPort(destination)
+	if err != nil {
+		hostname = destination
+		port = ""
+	}
+
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+
+	for _, allowed := range c.hosts {
+		allowedHostname, allowedPort, err := net.SplitHostPort(allowed)
+		if err != nil {
+			allowedHostname = allowed
+			allowedPort = ""
+		}
+
+		if allowedPort != "" && allowedPort != port {
+			continue
+		}
+
+		allowedHostname = strings.ToLower(strings.TrimSuffix(allowedHostname, "."))
+
+		if suffix, ok := strings.CutPrefix(allowedHostname, "*."); ok {
+			if suffix != "" && strings.HasSuffix(hostname, "."+suffix) {
+				return true
+			}
+
+			continue
+		}
+
+		if hostname == allowedHostname && !strings.Contains(allowedHostname, "*") {
+			return true
+		}
+	}
+
+	return false
+}
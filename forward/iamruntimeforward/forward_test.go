@@ -0,0 +1,40 @@
+package iamruntimeforward
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowed(t *testing.T) {
+	config := newConfig([]Option{WithAllowedHosts("api.example.com", "*.internal.example.com", "secure.example.com:8443")})
+
+	testCases := []struct {
+		destination string
+		expect      bool
+	}{
+		{"api.example.com:443", true},
+		{"API.example.com.:443", true},
+		{"api.example.com", true},
+		{"other.example.com:443", false},
+		{"svc.internal.example.com:443", true},
+		{"a.svc.internal.example.com:80", true},
+		{"internal.example.com:443", false},
+		{"evilinternal.example.com:443", false},
+		{"secure.example.com:8443", true},
+		{"secure.example.com:443", false},
+		{"api.example.com.evil.com:443", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.destination, func(t *testing.T) {
+			assert.Equal(t, tc.expect, config.allowed(tc.destination), "unexpected result")
+		})
+	}
+
+	assert.False(t, newConfig(nil).allowed("api.example.com:443"), "expected no hosts to be allowed by default")
+
+	for _, host := range []string{"*", "*:443", "*example.com"} {
+		assert.False(t, newConfig([]Option{WithAllowedHosts(host)}).allowed("api.example.com:443"), "expected %q not to allow destinations", host)
+	}
+}
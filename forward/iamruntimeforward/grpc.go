@@ -0,0 +1,101 @@
+package iamruntimeforward
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryClientInterceptor returns a [grpc.UnaryClientInterceptor] which forwards the credential from the call context
+// as a bearer token in the authorization metadata.
+//
+// See [StreamClientInterceptor] for details.
+func UnaryClientInterceptor(opts ...Option) grpc.UnaryClientInterceptor {
+	config := newConfig(opts)
+
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		callOpts, err := config.callOptions(ctx, cc, callOpts)
+		if err != nil {
+			return err
+		}
+
+		return invoker(ctx, method, req, reply, cc, callOpts...)
+	}
+}
+
+// StreamClientInterceptor returns a [grpc.StreamClientInterceptor] which forwards the credential from the stream context
+// as a bearer token in the authorization metadata.
+//
+// Calls which already have authorization metadata are sent unchanged.
+// The destination is the host of the client connection target.
+// Calls to destinations which are not allowed fail with [iamruntime.ErrForwardingNotAllowed].
+// Calls over connections without transport security fail unless [WithInsecure] is set.
+func StreamClientInterceptor(opts ...Option) grpc.StreamClientInterceptor {
+	config := newConfig(opts)
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		callOpts, err := config.callOptions(ctx, cc, callOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		return streamer(ctx, desc, cc, method, callOpts...)
+	}
+}
+
+// callOptions returns the call options with the credential added as per-RPC credentials,
+// so gRPC rejects the call if the connection does not have the required transport security.
+func (c config) callOptions(ctx context.Context, cc *grpc.ClientConn, callOpts []grpc.CallOption) ([]grpc.CallOption, error) {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get("authorization")) != 0 {
+		return callOpts, nil
+	}
+
+	credential, err := c.credential(ctx, target(cc))
+	if err != nil || credential == "" {
+		return callOpts, err
+	}
+
+	return append(callOpts, grpc.PerRPCCredentials(bearerCredentials{
+		token:    credential,
+		insecure: c.insecure,
+	})), nil
+}
+
+var _ credentials.PerRPCCredentials = bearerCredentials{}
+
+// bearerCredentials are [credentials.PerRPCCredentials] sending the token as a bearer token.
+type bearerCredentials struct {
+	token    string
+	insecure bool
+}
+
+// GetRequestMetadata returns the authorization metadata.
+func (b bearerCredentials) GetRequestMetadata(_ context.Context, _ ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + b.token}, nil
+}
+
+// RequireTransportSecurity returns true unless insecure forwarding is allowed.
+func (b bearerCredentials) RequireTransportSecurity() bool {
+	return !b.insecure
+}
+
+// target returns the host and port of the client connection target,
+// for example "api.example.com:443" for "dns:///api.example.com:443".
+func target(cc *grpc.ClientConn) string {
+	canonical := cc.CanonicalTarget()
+
+	parsed, err := url.Parse(canonical)
+	if err != nil {
+		return canonical
+	}
+
+	if parsed.Opaque != "" {
+		return parsed.Opaque
+	}
+
+	return strings.TrimPrefix(parsed.Path, "/")
+}
@@ -0,0 +1,212 @@
+package iamruntimeforward
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	testCases := []struct {
+		name          string
+		target        string
+		credential    *iamruntime.Credential
+		authorization string
+		expectMD      []string
+		expectError   error
+	}{
+		{
+			"forwarded",
+			"dns:///inventory.example.com:443",
+			iamruntime.NewOpaqueCredential("user-token"),
+			"",
+			[]string{"Bearer user-token"},
+			nil,
+		},
+		{
+			"passthrough target",
+			"passthrough:///inventory.example.com:443",
+			iamruntime.NewOpaqueCredential("user-token"),
+			"",
+			[]string{"Bearer user-token"},
+			nil,
+		},
+		{
+			"host not allowed",
+			"dns:///other.example.com:443",
+			iamruntime.NewOpaqueCredential("user-token"),
+			"",
+			nil,
+			iamruntime.ErrForwardingNotAllowed,
+		},
+		{
+			"no credential",
+			"dns:///inventory.example.com:443",
+			nil,
+			"",
+			nil,
+			nil,
+		},
+		{
+			"existing authorization",
+			"dns:///other.example.com:443",
+			iamruntime.NewOpaqueCredential("user-token"),
+			"Bearer service-token",
+			[]string{"Bearer service-token"},
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, err := grpc.NewClient(tc.target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			require.NoError(t, err, "unexpected error creating client")
+
+			t.Cleanup(func() { conn.Close() })
+
+			ctx := iamruntime.SetContextCredential(context.Background(), tc.credential)
+
+			if tc.authorization != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tc.authorization)
+			}
+
+			var (
+				called        bool
+				authorization []string
+			)
+
+			invoker := func(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+				called = true
+				authorization = callAuthorization(t, ctx, opts)
+
+				return nil
+			}
+
+			interceptor := UnaryClientInterceptor(WithAllowedHosts("inventory.example.com"))
+
+			err = interceptor(ctx, "/inventory.v1.Inventory/GetServer", nil, nil, conn, invoker)
+
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError, "unexpected error returned")
+				assert.False(t, called, "expected call not to be sent")
+
+				return
+			}
+
+			require.NoError(t, err, "unexpected error returned")
+			assert.True(t, called, "expected call to be sent")
+			assert.Equal(t, tc.expectMD, authorization, "unexpected authorization metadata")
+		})
+	}
+}
+
+// callAuthorization returns the authorization metadata sent with a call,
+// from both the outgoing context and per-RPC credentials.
+func callAuthorization(t *testing.T, ctx context.Context, opts []grpc.CallOption) []string {
+	t.Helper()
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+
+	authorization := md.Get("authorization")
+
+	for _, opt := range opts {
+		if creds, ok := opt.(grpc.PerRPCCredsCallOption); ok {
+			assert.True(t, creds.Creds.RequireTransportSecurity(), "expected transport security to be required")
+
+			perRPC, err := creds.Creds.GetRequestMetadata(ctx)
+			require.NoError(t, err, "unexpected error getting request metadata")
+
+			authorization = append(authorization, perRPC["authorization"])
+		}
+	}
+
+	return authorization
+}
+
+func TestUnaryClientInterceptorTransportSecurity(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "unexpected error listening")
+
+	received := make(chan []string, 1)
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		received <- md.Get("authorization")
+
+		return handler(ctx, req)
+	}))
+
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+
+	go server.Serve(listener) //nolint:errcheck // server is stopped on cleanup.
+
+	t.Cleanup(server.Stop)
+
+	testCases := []struct {
+		name       string
+		opts       []Option
+		expectCode codes.Code
+	}{
+		{
+			"insecure connection rejected",
+			[]Option{WithAllowedHosts("127.0.0.1")},
+			codes.Unauthenticated,
+		},
+		{
+			"insecure connection allowed",
+			[]Option{WithAllowedHosts("127.0.0.1"), WithInsecure()},
+			codes.OK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, err := grpc.NewClient(listener.Addr().String(),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithUnaryInterceptor(UnaryClientInterceptor(tc.opts...)),
+			)
+			require.NoError(t, err, "unexpected error creating client")
+
+			t.Cleanup(func() { conn.Close() })
+
+			ctx := iamruntime.SetContextCredential(context.Background(), iamruntime.NewOpaqueCredential("user-token"))
+
+			_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+
+			require.Equal(t, tc.expectCode, status.Code(err), "unexpected status code: %v", err)
+
+			if tc.expectCode == codes.OK {
+				assert.Equal(t, []string{"Bearer user-token"}, <-received, "unexpected authorization received")
+			}
+		})
+	}
+}
+
+func ExampleUnaryClientInterceptor() {
+	conn, err := grpc.NewClient("dns:///inventory.example.com:443",
+		grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(nil, "")),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(WithAllowedHosts("inventory.example.com"))),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(WithAllowedHosts("inventory.example.com"))),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	defer conn.Close()
+
+	// Calls made with the context of an authenticated request forward the request credential.
+}
@@ -0,0 +1,89 @@
+package iamruntimeforward
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// Transport is an [http.RoundTripper] which forwards the credential from the request context as a bearer token.
+//
+// Requests which already have an Authorization header are sent unchanged.
+// Requests to destinations which are not allowed, or to http URLs unless [WithInsecure] is set,
+// fail with [iamruntime.ErrForwardingNotAllowed] rather than being sent without the credential.
+type Transport struct {
+	// Base is the transport used to send requests.
+	// Default is [http.DefaultTransport].
+	Base http.RoundTripper
+
+	config config
+}
+
+// RoundTrip adds the credential from the request context to the request and sends it.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.base().RoundTrip(req)
+	}
+
+	credential, err := t.config.credential(req.Context(), destination(req))
+	if err == nil && credential != "" && req.URL.Scheme != "https" && !t.config.insecure {
+		err = fmt.Errorf("%w: %s: scheme %q is not secure", iamruntime.ErrForwardingNotAllowed, req.URL.Host, req.URL.Scheme)
+	}
+
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+
+		return nil, err
+	}
+
+	if credential == "" {
+		return t.base().RoundTrip(req)
+	}
+
+	forwarded := req.Clone(req.Context())
+
+	forwarded.Header.Set("Authorization", "Bearer "+credential)
+
+	return t.base().RoundTrip(forwarded)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+
+	return http.DefaultTransport
+}
+
+// destination returns the host and port of the request, using the default port for the scheme if none is set.
+func destination(req *http.Request) string {
+	port := req.URL.Port()
+
+	if port == "" {
+		switch req.URL.Scheme {
+		case "https":
+			port = "443"
+		case "http":
+			port = "80"
+		default:
+			return req.URL.Hostname()
+		}
+	}
+
+	return net.JoinHostPort(req.URL.Hostname(), port)
+}
+
+// NewTransport returns a new [Transport] sending requests with the provided base transport.
+// If base is nil, [http.DefaultTransport] is used.
+func NewTransport(base http.RoundTripper, opts ...Option) *Transport {
+	return &Transport{
+		Base:   base,
+		config: newConfig(opts),
+	}
+}
@@ -0,0 +1,171 @@
+package iamruntimeforward
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+func TestTransport(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	})
+
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	insecureServer := httptest.NewServer(handler)
+	t.Cleanup(insecureServer.Close)
+
+	testCases := []struct {
+		name          string
+		insecure      bool
+		opts          []Option
+		credential    *iamruntime.Credential
+		authorization string
+		expectHeader  string
+		expectError   error
+	}{
+		{
+			"forwarded",
+			false,
+			[]Option{WithAllowedHosts("127.0.0.1")},
+			iamruntime.NewOpaqueCredential("user-token"),
+			"",
+			"Bearer user-token",
+			nil,
+		},
+		{
+			"host not allowed",
+			false,
+			[]Option{WithAllowedHosts("api.example.com")},
+			iamruntime.NewOpaqueCredential("user-token"),
+			"",
+			"",
+			iamruntime.ErrForwardingNotAllowed,
+		},
+		{
+			"no credential",
+			false,
+			[]Option{WithAllowedHosts("127.0.0.1")},
+			nil,
+			"",
+			"",
+			nil,
+		},
+		{
+			"credential required",
+			false,
+			[]Option{WithAllowedHosts("127.0.0.1"), WithRequireCredential()},
+			nil,
+			"",
+			"",
+			iamruntime.ErrTokenNotFound,
+		},
+		{
+			"existing authorization",
+			false,
+			nil,
+			iamruntime.NewOpaqueCredential("user-token"),
+			"Bearer service-token",
+			"Bearer service-token",
+			nil,
+		},
+		{
+			"insecure scheme",
+			true,
+			[]Option{WithAllowedHosts("127.0.0.1")},
+			iamruntime.NewOpaqueCredential("user-token"),
+			"",
+			"",
+			iamruntime.ErrForwardingNotAllowed,
+		},
+		{
+			"insecure scheme allowed",
+			true,
+			[]Option{WithAllowedHosts("127.0.0.1"), WithInsecure()},
+			iamruntime.NewOpaqueCredential("user-token"),
+			"",
+			"Bearer user-token",
+			nil,
+		},
+		{
+			"insecure scheme without credential",
+			true,
+			[]Option{WithAllowedHosts("127.0.0.1")},
+			nil,
+			"",
+			"",
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &http.Client{
+				Transport: NewTransport(server.Client().Transport, tc.opts...),
+			}
+
+			url := server.URL
+
+			if tc.insecure {
+				url = insecureServer.URL
+			}
+
+			ctx := iamruntime.SetContextCredential(context.Background(), tc.credential)
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			resp, err := client.Do(req)
+
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError, "unexpected error returned")
+
+				return
+			}
+
+			require.NoError(t, err, "unexpected error sending request")
+
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectHeader, string(body), "unexpected authorization header")
+			assert.Equal(t, tc.authorization, req.Header.Get("Authorization"), "expected request not to be modified")
+		})
+	}
+}
+
+func ExampleNewTransport() {
+	client := &http.Client{
+		Transport: NewTransport(nil, WithAllowedHosts("inventory.example.com")),
+	}
+
+	// ctx is the request context of an authenticated request, such as echo's c.Request().Context().
+	ctx := context.TODO()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://inventory.example.com/v1/servers", nil)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Println("request failed:", err)
+
+		return
+	}
+
+	resp.Body.Close()
+}
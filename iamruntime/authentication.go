@@ -2,7 +2,6 @@ package iamruntime
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
 	"google.golang.org/grpc"
@@ -13,19 +12,27 @@ import (
 // The runtime must implement the iam-runtime's AuthenticationClient.
 // Use [SetContextRuntime] to set this value.
 func ContextValidateCredential(ctx context.Context, in *authentication.ValidateCredentialRequest, opts ...grpc.CallOption) error {
+	_, err := ContextValidateCredentialSubject(ctx, in, opts...)
+
+	return err
+}
+
+// ContextValidateCredentialSubject is the same as [ContextValidateCredential] except the subject returned by the runtime is returned.
+// The subject may be nil if the runtime does not return one.
+func ContextValidateCredentialSubject(ctx context.Context, in *authentication.ValidateCredentialRequest, opts ...grpc.CallOption) (*authentication.Subject, error) {
 	runtime := ContextRuntimeAuthenticationClient(ctx)
 	if runtime == nil {
-		return ErrRuntimeNotFound
+		return nil, ErrRuntimeNotFound
 	}
 
 	resp, err := runtime.ValidateCredential(ctx, in, opts...)
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrCredentialValidationRequestFailed, err)
+		return nil, NewRuntimeCallError(ErrCredentialValidationRequestFailed, "ValidateCredential", err)
 	}
 
 	if resp.Result == authentication.ValidateCredentialResponse_RESULT_INVALID {
-		return ErrInvalidCredentials
+		return nil, ErrInvalidCredentials
 	}
 
-	return nil
+	return resp.Subject, nil
 }
@@ -76,6 +76,73 @@ func TestValidateCredential(t *testing.T) {
 	}
 }
 
+func TestContextValidateCredentialSubject(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		authenticationResponse *authentication.ValidateCredentialResponse
+		authenticationError    error
+		expectSubject          string
+		expectError            error
+	}{
+		{
+			"permitted",
+			&authentication.ValidateCredentialResponse{
+				Result:  authentication.ValidateCredentialResponse_RESULT_VALID,
+				Subject: &authentication.Subject{SubjectId: "idntusr-abc123"},
+			},
+			nil,
+			"idntusr-abc123",
+			nil,
+		},
+		{
+			"no subject",
+			&authentication.ValidateCredentialResponse{Result: authentication.ValidateCredentialResponse_RESULT_VALID},
+			nil,
+			"",
+			nil,
+		},
+		{
+			"denied",
+			&authentication.ValidateCredentialResponse{Result: authentication.ValidateCredentialResponse_RESULT_INVALID},
+			nil,
+			"",
+			ErrInvalidCredentials,
+		},
+		{
+			"failed request",
+			nil,
+			grpc.ErrServerStopped,
+			"",
+			ErrCredentialValidationRequestFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			runtime.Mock.On("ValidateCredential", "some-api-key").Return(tc.authenticationResponse, tc.authenticationError)
+
+			ctx := SetContextRuntime(context.Background(), runtime)
+
+			subject, err := ContextValidateCredentialSubject(ctx, &authentication.ValidateCredentialRequest{
+				Credential: "some-api-key",
+			})
+
+			if tc.expectError != nil {
+				require.Error(t, err, "expected error to be returned")
+				assert.ErrorIs(t, err, tc.expectError, "unexpected error returned")
+			} else {
+				assert.NoError(t, err, "expected no error to be returned")
+			}
+
+			assert.Equal(t, tc.expectSubject, subject.GetSubjectId(), "unexpected subject returned")
+
+			runtime.Mock.AssertExpectations(t)
+		})
+	}
+}
+
 func ExampleContextValidateCredential() {
 	runtime, _ := NewClient("unix:///tmp/runtime.sock")
 
@@ -6,15 +6,17 @@ import (
 
 	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // ContextCheckAccess executes an access request on the runtime in the context.
-// Context must have a token and runtime value.
+// Context must have a credential and runtime value.
 // The runtime must implement the iam-runtime's AuthorizationClient.
-// Use [SetContextToken] and [SetContextRuntime] to set these values.
+// Use [SetContextCredential] or [SetContextToken] and [SetContextRuntime] to set these values.
 func ContextCheckAccess(ctx context.Context, actions []*authorization.AccessRequestAction, opts ...grpc.CallOption) error {
-	token := ContextToken(ctx)
-	if token == nil {
+	credential := ContextCredential(ctx)
+	if credential == nil {
 		return ErrTokenNotFound
 	}
 
@@ -23,16 +25,21 @@ func ContextCheckAccess(ctx context.Context, actions []*authorization.AccessRequ
 		return ErrRuntimeNotFound
 	}
 
+	markAccessChecked(ctx)
+
 	resp, err := runtime.CheckAccess(ctx, &authorization.CheckAccessRequest{
-		Credential: token.Raw,
+		Credential: credential.Raw,
 		Actions:    actions,
 	}, opts...)
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrAccessCheckFailed, err)
+		return NewRuntimeCallError(ErrAccessCheckFailed, "CheckAccess", err)
 	}
 
 	if resp.Result == authorization.CheckAccessResponse_RESULT_DENIED {
-		return ErrAccessDenied
+		return &AccessDeniedError{
+			Subject: ContextSubject(ctx),
+			Actions: actions,
+		}
 	}
 
 	return nil
@@ -69,7 +76,7 @@ func ContextCreateRelationships(ctx context.Context, in *authorization.CreateRel
 
 	resp, err := runtime.CreateRelationships(ctx, in, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("%w: create: %w", ErrRelationshipRequestFailed, err)
+		return nil, relationshipError("create", "CreateRelationships", err)
 	}
 
 	return resp, nil
@@ -87,8 +94,26 @@ func ContextDeleteRelationships(ctx context.Context, in *authorization.DeleteRel
 
 	resp, err := runtime.DeleteRelationships(ctx, in, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("%w: delete: %w", ErrRelationshipRequestFailed, err)
+		return nil, relationshipError("delete", "DeleteRelationships", err)
 	}
 
 	return resp, nil
 }
+
+// relationshipError classifies a relationship request error by the gRPC status code returned by the runtime.
+func relationshipError(operation, method string, err error) error {
+	kind := ErrRelationshipRequestFailed
+
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		kind = ErrRelationshipInvalid
+	case codes.AlreadyExists:
+		kind = ErrRelationshipConflict
+	case codes.NotFound:
+		kind = ErrRelationshipNotFound
+	case codes.PermissionDenied:
+		kind = ErrRelationshipPermissionDenied
+	}
+
+	return NewRuntimeCallError(fmt.Errorf("%w: %s", kind, operation), method, err)
+}
@@ -2,6 +2,7 @@ package iamruntime
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -10,6 +11,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/metal-toolbox/iam-runtime-contrib/internal/testauth"
 	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
@@ -98,6 +101,13 @@ func TestContextCheckAccess(t *testing.T) {
 			if tc.expectError != nil {
 				require.Error(t, err, "expected error to be returned")
 				assert.ErrorIs(t, err, tc.expectError, "unexpected error returned")
+
+				if errors.Is(tc.expectError, ErrAccessDenied) {
+					var deniedErr *AccessDeniedError
+
+					require.ErrorAs(t, err, &deniedErr, "expected access denied error")
+					assert.Equal(t, tc.actions, deniedErr.Actions, "unexpected denied actions")
+				}
 			} else {
 				assert.NoError(t, err, "expected no error to be returned")
 			}
@@ -222,6 +232,23 @@ func TestContextCreateRelationships(t *testing.T) {
 			},
 			ErrRelationshipRequestFailed,
 		},
+		{
+			"conflict",
+			&authorization.CreateRelationshipsRequest{
+				ResourceId: "testten-abc123",
+				Relationships: []*authorization.Relationship{
+					{
+						Relation:  "parent",
+						SubjectId: "testten-root123",
+					},
+				},
+			},
+			status.Error(codes.AlreadyExists, "conflict"),
+			map[string][]string{
+				"parent": {"testten-root123"},
+			},
+			ErrRelationshipConflict,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -290,6 +317,23 @@ func TestContextDeleteRelationships(t *testing.T) {
 			},
 			ErrRelationshipRequestFailed,
 		},
+		{
+			"not found",
+			&authorization.DeleteRelationshipsRequest{
+				ResourceId: "testten-abc123",
+				Relationships: []*authorization.Relationship{
+					{
+						Relation:  "parent",
+						SubjectId: "testten-root123",
+					},
+				},
+			},
+			status.Error(codes.NotFound, "not found"),
+			map[string][]string{
+				"parent": {"testten-root123"},
+			},
+			ErrRelationshipNotFound,
+		},
 	}
 
 	for _, tc := range testCases {
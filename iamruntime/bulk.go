@@ -0,0 +1,363 @@
+package iamruntime
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+)
+
+const (
+	defaultBulkChunkSize   = 100
+	defaultBulkConcurrency = 4
+)
+
+// BulkItem is a set of relationships from a resource to write in bulk.
+type BulkItem struct {
+	ResourceID    string
+	Relationships []Relationship
+}
+
+// BulkResult is the result of writing a bulk item.
+type BulkResult struct {
+	// Index is the position of the item in the provided items.
+	Index int
+
+	// Item is the item which was written.
+	Item BulkItem
+
+	// Err is the error writing the item, if any.
+	Err error
+}
+
+// BulkStats summarizes the progress of a bulk write.
+type BulkStats struct {
+	// Items is the number of items read.
+	Items int
+
+	// Succeeded is the number of items written.
+	Succeeded int
+
+	// Failed is the number of items which failed to be written.
+	Failed int
+
+	// Requests is the number of requests sent to the runtime.
+	Requests int
+}
+
+// BulkOption configures a bulk write.
+type BulkOption func(*bulkOptions)
+
+type bulkOptions struct {
+	chunkSize   int
+	concurrency int
+	limit       rate.Limit
+	burst       int
+	stopOnError bool
+	onResult    func(BulkResult)
+	onProgress  func(BulkStats)
+	callOpts    []grpc.CallOption
+}
+
+// BulkChunkSize sets the maximum number of relationships sent in a single request.
+// Default is 100.
+func BulkChunkSize(size int) BulkOption {
+	return func(o *bulkOptions) {
+		o.chunkSize = size
+	}
+}
+
+// BulkConcurrency sets the maximum number of concurrent requests.
+// Default is 4.
+func BulkConcurrency(concurrency int) BulkOption {
+	return func(o *bulkOptions) {
+		o.concurrency = concurrency
+	}
+}
+
+// BulkRateLimit limits the rate requests are sent to the runtime.
+// Default is no limit.
+func BulkRateLimit(limit rate.Limit, burst int) BulkOption {
+	return func(o *bulkOptions) {
+		o.limit = limit
+		o.burst = burst
+	}
+}
+
+// BulkStopOnError stops reading items once an item fails.
+// Requests already in progress are completed, items which were read but not yet sent fail with [context.Canceled].
+// Default is false, all items are written.
+func BulkStopOnError() BulkOption {
+	return func(o *bulkOptions) {
+		o.stopOnError = true
+	}
+}
+
+// BulkOnResult sets a function called with the result of every item.
+// Calls are serialized, but may be made from different goroutines and not in item order.
+func BulkOnResult(fn func(BulkResult)) BulkOption {
+	return func(o *bulkOptions) {
+		o.onResult = fn
+	}
+}
+
+// BulkOnProgress sets a function called with the current stats after every item completes.
+// Calls are serialized, but may be made from different goroutines.
+func BulkOnProgress(fn func(BulkStats)) BulkOption {
+	return func(o *bulkOptions) {
+		o.onProgress = fn
+	}
+}
+
+// BulkCallOptions sets the grpc call options used for every request.
+func BulkCallOptions(opts ...grpc.CallOption) BulkOption {
+	return func(o *bulkOptions) {
+		o.callOpts = append(o.callOpts, opts...)
+	}
+}
+
+// BulkCreateRelationships creates the relationships of the items using the runtime in the context.
+// See [BulkWriteRelationships] for more details.
+func BulkCreateRelationships(ctx context.Context, items iter.Seq[BulkItem], opts ...BulkOption) (BulkStats, error) {
+	return BulkWriteRelationships(ctx, RelationshipCreate, items, opts...)
+}
+
+// BulkDeleteRelationships deletes the relationships of the items using the runtime in the context.
+// See [BulkWriteRelationships] for more details.
+func BulkDeleteRelationships(ctx context.Context, items iter.Seq[BulkItem], opts ...BulkOption) (BulkStats, error) {
+	return BulkWriteRelationships(ctx, RelationshipDelete, items, opts...)
+}
+
+// bulkItem tracks the requests an item was split into.
+type bulkItem struct {
+	index   int
+	item    BulkItem
+	pending int
+	err     error
+}
+
+// bulkChunk is a single request, which may include relationships of multiple consecutive items with the same resource.
+type bulkChunk struct {
+	resourceID    string
+	relationships []Relationship
+	items         []*bulkItem
+}
+
+// bulkWriter executes the chunks of a bulk write.
+type bulkWriter struct {
+	operation RelationshipOperation
+	options   bulkOptions
+	limiter   *rate.Limiter
+	sem       chan struct{}
+	wg        sync.WaitGroup
+	cancel    context.CancelFunc
+
+	mu    sync.Mutex
+	stats BulkStats
+	first error
+}
+
+// BulkWriteRelationships applies the operation to the relationships of the items using the runtime in the context.
+//
+// Items are read in order and their relationships sent in requests of at most the chunk size.
+// Consecutive items with the same resource id are combined into the same request when they fit.
+// Requests are sent with bounded concurrency and, if configured, rate limited.
+//
+// Items with invalid ids fail without being sent. An item fails if any request containing its relationships fails.
+// Use [BulkOnResult] and [BulkOnProgress] to observe results as they complete.
+//
+// The final stats are returned. If any item failed, an error wrapping [ErrRelationshipBulkFailed]
+// and the first item error is returned.
+func BulkWriteRelationships(ctx context.Context, operation RelationshipOperation, items iter.Seq[BulkItem], opts ...BulkOption) (BulkStats, error) {
+	options := bulkOptions{
+		chunkSize:   defaultBulkChunkSize,
+		concurrency: defaultBulkConcurrency,
+		limit:       rate.Inf,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	options.chunkSize = max(options.chunkSize, 1)
+	options.concurrency = max(options.concurrency, 1)
+
+	// Stopping on error cancels reading and sending items, requests in progress complete with the parent context.
+	stop, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	writer := &bulkWriter{
+		operation: operation,
+		options:   options,
+		limiter:   rate.NewLimiter(options.limit, max(options.burst, 1)),
+		sem:       make(chan struct{}, options.concurrency),
+		cancel:    cancel,
+	}
+
+	var (
+		chunk *bulkChunk
+		index int
+	)
+
+	for item := range items {
+		if stop.Err() != nil {
+			break
+		}
+
+		state := &bulkItem{
+			index: index,
+			item:  item,
+		}
+
+		index++
+
+		writer.mu.Lock()
+		writer.stats.Items++
+		writer.mu.Unlock()
+
+		if err := validateRelationships(item.ResourceID, item.Relationships); err != nil {
+			state.err = err
+			writer.complete(state)
+
+			continue
+		}
+
+		// Hold the item until all of its relationships have been assigned to chunks.
+		state.pending = 1
+
+		for _, rel := range item.Relationships {
+			if chunk != nil && (chunk.resourceID != item.ResourceID || len(chunk.relationships) == options.chunkSize) {
+				writer.send(ctx, stop, chunk)
+
+				chunk = nil
+			}
+
+			if chunk == nil {
+				chunk = &bulkChunk{resourceID: item.ResourceID}
+			}
+
+			if len(chunk.items) == 0 || chunk.items[len(chunk.items)-1] != state {
+				chunk.items = append(chunk.items, state)
+
+				writer.mu.Lock()
+				state.pending++
+				writer.mu.Unlock()
+			}
+
+			chunk.relationships = append(chunk.relationships, rel)
+		}
+
+		writer.release(state, nil)
+	}
+
+	if chunk != nil {
+		writer.send(ctx, stop, chunk)
+	}
+
+	writer.wg.Wait()
+
+	if writer.stats.Failed != 0 {
+		return writer.stats, fmt.Errorf("%w: %d of %d items failed: %w", ErrRelationshipBulkFailed, writer.stats.Failed, writer.stats.Items, writer.first)
+	}
+
+	return writer.stats, nil
+}
+
+// send executes the chunk with the context once a concurrency slot and the rate limiter allow.
+// If the stop context is canceled first, the items of the chunk fail with its error.
+func (w *bulkWriter) send(ctx, stop context.Context, chunk *bulkChunk) {
+	select {
+	case w.sem <- struct{}{}:
+	case <-stop.Done():
+		w.finish(chunk, stop.Err())
+
+		return
+	}
+
+	if err := w.limiter.Wait(stop); err != nil {
+		<-w.sem
+
+		w.finish(chunk, err)
+
+		return
+	}
+
+	w.mu.Lock()
+	w.stats.Requests++
+	w.mu.Unlock()
+
+	w.wg.Add(1)
+
+	go func() {
+		defer w.wg.Done()
+		defer func() { <-w.sem }()
+
+		err := RelationshipWrite{
+			Operation:     w.operation,
+			ResourceID:    chunk.resourceID,
+			Relationships: chunk.relationships,
+		}.Apply(ctx, w.options.callOpts...)
+
+		w.finish(chunk, err)
+	}()
+}
+
+// finish releases the items of a completed chunk.
+func (w *bulkWriter) finish(chunk *bulkChunk, err error) {
+	for _, item := range chunk.items {
+		w.release(item, err)
+	}
+}
+
+// release records the error for the item and completes it once all of its chunks have finished.
+func (w *bulkWriter) release(item *bulkItem, err error) {
+	w.mu.Lock()
+
+	if err != nil && item.err == nil {
+		item.err = err
+	}
+
+	item.pending--
+	done := item.pending == 0
+
+	w.mu.Unlock()
+
+	if done {
+		w.complete(item)
+	}
+}
+
+// complete records the result of the item and reports it.
+func (w *bulkWriter) complete(item *bulkItem) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if item.err != nil {
+		w.stats.Failed++
+
+		if w.first == nil {
+			w.first = item.err
+		}
+
+		if w.options.stopOnError {
+			w.cancel()
+		}
+	} else {
+		w.stats.Succeeded++
+	}
+
+	if w.options.onResult != nil {
+		w.options.onResult(BulkResult{
+			Index: item.index,
+			Item:  item.item,
+			Err:   item.err,
+		})
+	}
+
+	if w.options.onProgress != nil {
+		w.options.onProgress(w.stats)
+	}
+}
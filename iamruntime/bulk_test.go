@@ -0,0 +1,296 @@
+package iamruntime
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestBulkWriteRelationships(t *testing.T) {
+	items := []BulkItem{
+		{"testten-abc123", []Relationship{{RelationParent, "testten-root123"}}},
+		{"testten-abc123", []Relationship{{RelationMember, "idntusr-member1"}, {RelationMember, "idntusr-member2"}}},
+		{"testten-def456", []Relationship{{RelationParent, "testten-root123"}}},
+	}
+
+	type call struct {
+		resourceID    string
+		relationships map[string][]string
+		err           error
+	}
+
+	testCases := []struct {
+		name          string
+		operation     RelationshipOperation
+		items         []BulkItem
+		opts          []BulkOption
+		calls         []call
+		expectStats   BulkStats
+		expectFailed  []int
+		expectError   error
+		expectItemErr error
+	}{
+		{
+			"grouped",
+			RelationshipCreate,
+			items,
+			nil,
+			[]call{
+				{"testten-abc123", map[string][]string{"parent": {"testten-root123"}, "member": {"idntusr-member1", "idntusr-member2"}}, nil},
+				{"testten-def456", map[string][]string{"parent": {"testten-root123"}}, nil},
+			},
+			BulkStats{Items: 3, Succeeded: 3, Requests: 2},
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"chunked",
+			RelationshipDelete,
+			items,
+			[]BulkOption{BulkChunkSize(2), BulkConcurrency(1), BulkRateLimit(rate.Inf, 1)},
+			[]call{
+				{"testten-abc123", map[string][]string{"parent": {"testten-root123"}, "member": {"idntusr-member1"}}, nil},
+				{"testten-abc123", map[string][]string{"member": {"idntusr-member2"}}, nil},
+				{"testten-def456", map[string][]string{"parent": {"testten-root123"}}, nil},
+			},
+			BulkStats{Items: 3, Succeeded: 3, Requests: 3},
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"chunk failed",
+			RelationshipCreate,
+			items,
+			[]BulkOption{BulkChunkSize(2)},
+			[]call{
+				{"testten-abc123", map[string][]string{"parent": {"testten-root123"}, "member": {"idntusr-member1"}}, nil},
+				{"testten-abc123", map[string][]string{"member": {"idntusr-member2"}}, grpc.ErrServerStopped},
+				{"testten-def456", map[string][]string{"parent": {"testten-root123"}}, nil},
+			},
+			BulkStats{Items: 3, Succeeded: 2, Failed: 1, Requests: 3},
+			[]int{1},
+			ErrRelationshipBulkFailed,
+			ErrRelationshipRequestFailed,
+		},
+		{
+			"invalid item",
+			RelationshipCreate,
+			append([]BulkItem{{"abc123", []Relationship{{RelationParent, "testten-root123"}}}}, items[2]),
+			nil,
+			[]call{
+				{"testten-def456", map[string][]string{"parent": {"testten-root123"}}, nil},
+			},
+			BulkStats{Items: 2, Succeeded: 1, Failed: 1, Requests: 1},
+			[]int{0},
+			ErrRelationshipBulkFailed,
+			ErrRelationshipIDInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			method := "CreateRelationships"
+			if tc.operation == RelationshipDelete {
+				method = "DeleteRelationships"
+			}
+
+			for _, c := range tc.calls {
+				runtime.Mock.On(method, c.resourceID, c.relationships).Return(c.err).Once()
+			}
+
+			ctx := SetContextRuntime(context.Background(), runtime)
+
+			var (
+				results  []BulkResult
+				progress []BulkStats
+			)
+
+			opts := append(slices.Clone(tc.opts),
+				BulkOnResult(func(result BulkResult) { results = append(results, result) }),
+				BulkOnProgress(func(stats BulkStats) { progress = append(progress, stats) }),
+			)
+
+			stats, err := BulkWriteRelationships(ctx, tc.operation, slices.Values(tc.items), opts...)
+
+			runtime.Mock.AssertExpectations(t)
+
+			assert.Equal(t, tc.expectStats, stats, "unexpected stats")
+
+			require.Len(t, results, len(tc.items), "expected a result for every item")
+			require.Len(t, progress, len(tc.items), "expected progress for every item")
+			assert.Equal(t, stats, progress[len(progress)-1], "expected final progress to match stats")
+
+			sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+
+			var failed []int
+
+			for i, result := range results {
+				assert.Equal(t, i, result.Index, "unexpected result index")
+				assert.Equal(t, tc.items[i], result.Item, "unexpected result item")
+
+				if result.Err != nil {
+					failed = append(failed, result.Index)
+
+					assert.ErrorIs(t, result.Err, tc.expectItemErr, "unexpected item error")
+				}
+			}
+
+			assert.Equal(t, tc.expectFailed, failed, "unexpected failed items")
+
+			if tc.expectError == nil {
+				assert.NoError(t, err, "expected no error to be returned")
+
+				return
+			}
+
+			assert.ErrorIs(t, err, tc.expectError, "unexpected error returned")
+			assert.ErrorIs(t, err, tc.expectItemErr, "expected first item error to be wrapped")
+		})
+	}
+}
+
+func TestBulkWriteRelationshipsStopOnError(t *testing.T) {
+	runtime := new(mockruntime.MockRuntime)
+
+	runtime.Mock.On("CreateRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root123"}}).Return(grpc.ErrServerStopped).Once()
+
+	ctx := SetContextRuntime(context.Background(), runtime)
+
+	items := []BulkItem{
+		{"testten-abc123", []Relationship{{RelationParent, "testten-root123"}}},
+		{"testten-def456", []Relationship{{RelationParent, "testten-root123"}}},
+		{"testten-ghi789", []Relationship{{RelationParent, "testten-root123"}}},
+		{"testten-jkl012", []Relationship{{RelationParent, "testten-root123"}}},
+	}
+
+	var results []BulkResult
+
+	stats, err := BulkCreateRelationships(ctx, slices.Values(items),
+		BulkConcurrency(1),
+		BulkStopOnError(),
+		BulkOnResult(func(result BulkResult) { results = append(results, result) }),
+	)
+
+	runtime.Mock.AssertExpectations(t)
+
+	require.ErrorIs(t, err, ErrRelationshipBulkFailed, "expected bulk error")
+	assert.ErrorIs(t, err, ErrRelationshipRequestFailed, "expected first error to be wrapped")
+
+	// The failure stops the remaining items from being read, items already read are not sent.
+	assert.Equal(t, BulkStats{Items: 3, Failed: 3, Requests: 1}, stats, "unexpected stats")
+	require.Len(t, results, 3, "expected a result for every item read")
+
+	for _, result := range results[1:] {
+		assert.ErrorIs(t, result.Err, context.Canceled, "expected unsent items to fail with the context error")
+	}
+}
+
+// slowAuthorizationClient creates relationships for the slow resource once released, failing if its context is canceled.
+// Requests for other resources fail once the slow request has started.
+type slowAuthorizationClient struct {
+	authorization.AuthorizationClient
+
+	slow     string
+	started  chan struct{}
+	released chan struct{}
+}
+
+func (c slowAuthorizationClient) CreateRelationships(ctx context.Context, in *authorization.CreateRelationshipsRequest, _ ...grpc.CallOption) (*authorization.CreateRelationshipsResponse, error) {
+	if in.ResourceId != c.slow {
+		<-c.started
+
+		return nil, grpc.ErrServerStopped
+	}
+
+	close(c.started)
+
+	select {
+	case <-c.released:
+	case <-ctx.Done():
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &authorization.CreateRelationshipsResponse{}, nil
+}
+
+func TestBulkWriteRelationshipsStopOnErrorInProgress(t *testing.T) {
+	runtime := slowAuthorizationClient{
+		slow:     "testten-abc123",
+		started:  make(chan struct{}),
+		released: make(chan struct{}),
+	}
+
+	ctx := SetContextRuntimeAny(context.Background(), runtime)
+
+	items := []BulkItem{
+		{"testten-abc123", []Relationship{{RelationParent, "testten-root123"}}},
+		{"testten-def456", []Relationship{{RelationParent, "testten-root123"}}},
+	}
+
+	results := make(map[int]error)
+
+	stats, err := BulkCreateRelationships(ctx, slices.Values(items),
+		BulkConcurrency(2),
+		BulkStopOnError(),
+		BulkOnResult(func(result BulkResult) {
+			results[result.Index] = result.Err
+
+			// Release the slow request once the other item has failed and stopped the write.
+			if result.Index == 1 {
+				close(runtime.released)
+			}
+		}),
+	)
+
+	require.ErrorIs(t, err, ErrRelationshipBulkFailed, "expected bulk error")
+
+	assert.Equal(t, BulkStats{Items: 2, Succeeded: 1, Failed: 1, Requests: 2}, stats, "unexpected stats")
+	assert.NoError(t, results[0], "expected request in progress to complete")
+	assert.ErrorIs(t, results[1], ErrRelationshipRequestFailed, "expected failed item error")
+}
+
+func ExampleBulkCreateRelationships() {
+	ctx := context.TODO()
+
+	loadBalancers := []string{"loadbal-abc123", "loadbal-def456", "loadbal-ghi789"}
+
+	items := func(yield func(BulkItem) bool) {
+		for _, id := range loadBalancers {
+			if !yield(BulkItem{id, Relate(id).Parent("tnntten-root123").Relationships()}) {
+				return
+			}
+		}
+	}
+
+	stats, err := BulkCreateRelationships(ctx, items,
+		BulkConcurrency(8),
+		BulkRateLimit(100, 10),
+		BulkOnResult(func(result BulkResult) {
+			if result.Err != nil {
+				fmt.Println("failed to create relationships for", result.Item.ResourceID, result.Err)
+			}
+		}),
+	)
+	if err != nil {
+		fmt.Println("bulk create failed:", err)
+	}
+
+	fmt.Printf("created relationships for %d of %d load balancers\n", stats.Succeeded, stats.Items)
+}
@@ -0,0 +1,147 @@
+package iamruntime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// StandardClaims contains the registered jwt claims along with the OAuth scope claims.
+type StandardClaims struct {
+	jwt.RegisteredClaims
+
+	// Scope is the space delimited OAuth scope claim.
+	Scope string `json:"scope,omitempty"`
+
+	// Scp is the scope claim as a list, as issued by some identity providers.
+	Scp jwt.ClaimStrings `json:"scp,omitempty"`
+}
+
+// Scopes returns the unique scopes from both the scope and scp claims.
+func (c StandardClaims) Scopes() []string {
+	var scopes []string
+
+	for _, scope := range append(strings.Fields(c.Scope), c.Scp...) {
+		if scope != "" && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
+
+// ContextClaims decodes the claims of the credential in the provided context into T.
+// Claims are decoded from the jwt token, or for opaque credentials, from the subject claims returned by the runtime.
+// Claims are decoded as JSON, so T should use json struct tags.
+//
+// Decoded claims are memoized on the credential, so repeated lookups for the same type are not decoded again.
+// If T contains reference types such as maps or slices, they are shared between lookups and must not be modified.
+func ContextClaims[T any](ctx context.Context) (T, error) {
+	credential := ContextCredential(ctx)
+	if credential == nil {
+		var claims T
+
+		return claims, ErrTokenNotFound
+	}
+
+	if credential.IsOpaque() {
+		return decodeClaims[T](credential, ContextSubjectClaims(ctx))
+	}
+
+	return decodeClaims[T](credential, credential.Token.Claims)
+}
+
+// CredentialClaims decodes the jwt token claims of the provided credential into T.
+// Opaque credentials have no token claims, use [ContextClaims] to decode the subject claims returned by the runtime instead.
+//
+// See [ContextClaims] for details on decoding and memoization.
+func CredentialClaims[T any](credential *Credential) (T, error) {
+	if credential.IsOpaque() {
+		var claims T
+
+		return claims, fmt.Errorf("%w: opaque credentials have no token claims", ErrClaimsInvalid)
+	}
+
+	return decodeClaims[T](credential, credential.Token.Claims)
+}
+
+// decodeClaims decodes the source claims into T, memoizing the result on the credential.
+func decodeClaims[T any](credential *Credential, source any) (T, error) {
+	var claims T
+
+	claimsType := reflect.TypeFor[T]()
+
+	if cached, ok := credential.claims.Load(claimsType); ok {
+		return cached.(T), nil
+	}
+
+	data, err := json.Marshal(source)
+	if err != nil {
+		return claims, fmt.Errorf("%w: %w", ErrClaimsInvalid, err)
+	}
+
+	if err = json.Unmarshal(data, &claims); err != nil {
+		return claims, fmt.Errorf("%w: %w", ErrClaimsInvalid, err)
+	}
+
+	credential.claims.Store(claimsType, claims)
+
+	return claims, nil
+}
+
+// ContextStandardClaims decodes the standard claims of the credential in the provided context.
+func ContextStandardClaims(ctx context.Context) (StandardClaims, error) {
+	return ContextClaims[StandardClaims](ctx)
+}
+
+// ContextAudience returns the audience claim of the credential in the provided context.
+func ContextAudience(ctx context.Context) ([]string, error) {
+	claims, err := ContextStandardClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims.Audience, nil
+}
+
+// ContextIssuer returns the issuer claim of the credential in the provided context.
+func ContextIssuer(ctx context.Context) (string, error) {
+	claims, err := ContextStandardClaims(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return claims.Issuer, nil
+}
+
+// ContextScopes returns the scopes of the credential in the provided context.
+// Scopes are read from both the space delimited scope claim and the scp list claim.
+func ContextScopes(ctx context.Context) ([]string, error) {
+	claims, err := ContextStandardClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims.Scopes(), nil
+}
+
+// ContextExpiry returns the expiration time claim of the credential in the provided context.
+// If the credential has no expiration, a zero time is returned.
+func ContextExpiry(ctx context.Context) (time.Time, error) {
+	claims, err := ContextStandardClaims(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if claims.ExpiresAt == nil {
+		return time.Time{}, nil
+	}
+
+	return claims.ExpiresAt.Time, nil
+}
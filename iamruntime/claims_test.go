@@ -0,0 +1,205 @@
+package iamruntime
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tenantClaims struct {
+	Subject  string `json:"sub"`
+	TenantID string `json:"tenant_id"`
+	Email    string `json:"email"`
+}
+
+func TestContextClaims(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	testCases := []struct {
+		name          string
+		credential    *Credential
+		subjectClaims map[string]any
+		expectClaims  tenantClaims
+		expectError   error
+	}{
+		{
+			"token",
+			NewTokenCredential(&jwt.Token{
+				Claims: jwt.MapClaims{
+					"sub":       "idntusr-abc123",
+					"tenant_id": "tnntten-abc123",
+					"email":     "user@example.com",
+					"exp":       float64(expiry.Unix()),
+				},
+			}),
+			map[string]any{"tenant_id": "ignored"},
+			tenantClaims{
+				Subject:  "idntusr-abc123",
+				TenantID: "tnntten-abc123",
+				Email:    "user@example.com",
+			},
+			nil,
+		},
+		{
+			"opaque",
+			NewOpaqueCredential("some-api-key"),
+			map[string]any{"sub": "idntusr-abc123", "tenant_id": "tnntten-abc123"},
+			tenantClaims{
+				Subject:  "idntusr-abc123",
+				TenantID: "tnntten-abc123",
+			},
+			nil,
+		},
+		{
+			"invalid",
+			NewTokenCredential(&jwt.Token{
+				Claims: jwt.MapClaims{"tenant_id": 1},
+			}),
+			nil,
+			tenantClaims{},
+			ErrClaimsInvalid,
+		},
+		{
+			"missing",
+			nil,
+			nil,
+			tenantClaims{},
+			ErrTokenNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			if tc.credential != nil {
+				ctx = SetContextCredential(ctx, tc.credential)
+			}
+
+			ctx = SetContextSubjectClaims(ctx, tc.subjectClaims)
+
+			claims, err := ContextClaims[tenantClaims](ctx)
+
+			if tc.expectError != nil {
+				require.Error(t, err, "expected error to be returned")
+				assert.ErrorIs(t, err, tc.expectError, "unexpected error returned")
+
+				return
+			}
+
+			require.NoError(t, err, "expected no error to be returned")
+			assert.Equal(t, tc.expectClaims, claims, "unexpected claims returned")
+
+			// Claims are memoized, changes to the source claims are not reflected.
+			if tc.credential.Token != nil {
+				tc.credential.Token.Claims.(jwt.MapClaims)["email"] = "other@example.com"
+			}
+
+			claims, err = ContextClaims[tenantClaims](ctx)
+			require.NoError(t, err, "expected no error to be returned")
+			assert.Equal(t, tc.expectClaims, claims, "unexpected memoized claims returned")
+		})
+	}
+}
+
+func TestContextStandardClaims(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	testCases := []struct {
+		name           string
+		claims         jwt.MapClaims
+		expectAudience []string
+		expectIssuer   string
+		expectScopes   []string
+		expectExpiry   time.Time
+	}{
+		{
+			"scope string",
+			jwt.MapClaims{
+				"aud":   "https://api.example.com",
+				"iss":   "https://iam.example.com",
+				"scope": "lb:read lb:write",
+				"exp":   float64(expiry.Unix()),
+			},
+			[]string{"https://api.example.com"},
+			"https://iam.example.com",
+			[]string{"lb:read", "lb:write"},
+			expiry,
+		},
+		{
+			"scp list",
+			jwt.MapClaims{
+				"aud":   []any{"https://api.example.com", "https://other.example.com"},
+				"iss":   "https://iam.example.com",
+				"scope": "lb:read",
+				"scp":   []any{"lb:read", "lb:delete"},
+			},
+			[]string{"https://api.example.com", "https://other.example.com"},
+			"https://iam.example.com",
+			[]string{"lb:read", "lb:delete"},
+			time.Time{},
+		},
+		{
+			"empty",
+			jwt.MapClaims{},
+			nil,
+			"",
+			nil,
+			time.Time{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := SetContextToken(context.Background(), &jwt.Token{Claims: tc.claims})
+
+			audience, err := ContextAudience(ctx)
+			require.NoError(t, err, "unexpected error getting audience")
+			assert.Equal(t, tc.expectAudience, audience, "unexpected audience returned")
+
+			issuer, err := ContextIssuer(ctx)
+			require.NoError(t, err, "unexpected error getting issuer")
+			assert.Equal(t, tc.expectIssuer, issuer, "unexpected issuer returned")
+
+			scopes, err := ContextScopes(ctx)
+			require.NoError(t, err, "unexpected error getting scopes")
+			assert.Equal(t, tc.expectScopes, scopes, "unexpected scopes returned")
+
+			expiry, err := ContextExpiry(ctx)
+			require.NoError(t, err, "unexpected error getting expiry")
+			assert.True(t, tc.expectExpiry.Equal(expiry), "unexpected expiry returned")
+		})
+	}
+}
+
+func ExampleContextClaims() {
+	type Claims struct {
+		TenantID string `json:"tenant_id"`
+		Email    string `json:"email"`
+	}
+
+	ctx := SetContextToken(context.TODO(), &jwt.Token{
+		Claims: jwt.MapClaims{
+			"tenant_id": "tnntten-abc123",
+			"email":     "user@example.com",
+		},
+	})
+
+	claims, err := ContextClaims[Claims](ctx)
+	if err != nil {
+		fmt.Println("failed to decode claims", err)
+
+		return
+	}
+
+	fmt.Println("Tenant:", claims.TenantID)
+	fmt.Println("Email:", claims.Email)
+	// Output:
+	// Tenant: tnntten-abc123
+	// Email: user@example.com
+}
@@ -60,10 +60,20 @@ func ContextRuntimeIdentityClient(ctx context.Context) identity.IdentityClient {
 }
 
 // ContextToken retrieves the decoded jwt token from the provided context.
-// If the token is not found in the provided context, nil is returned.
+// If the token is not found in the provided context or the credential is opaque, nil is returned.
 func ContextToken(ctx context.Context) *jwt.Token {
-	if token, ok := ctx.Value(internal.TokenCtxKey).(*jwt.Token); ok {
-		return token
+	if credential := ContextCredential(ctx); credential != nil {
+		return credential.Token
+	}
+
+	return nil
+}
+
+// ContextCredential retrieves the credential from the provided context.
+// If the credential is not found in the provided context, nil is returned.
+func ContextCredential(ctx context.Context) *Credential {
+	if credential, ok := ctx.Value(internal.CredentialCtxKey).(*Credential); ok {
+		return credential
 	}
 
 	return nil
@@ -79,6 +89,26 @@ func ContextSubject(ctx context.Context) string {
 	return ""
 }
 
+// ContextSubjectClaims retrieves the subject claims returned by the runtime from the provided context.
+// If the claims are not found in the provided context, nil is returned.
+func ContextSubjectClaims(ctx context.Context) map[string]any {
+	if claims, ok := ctx.Value(internal.SubjectClaimsCtxKey).(map[string]any); ok {
+		return claims
+	}
+
+	return nil
+}
+
+// ContextAccessChecked returns true if an access check has been executed with the provided context.
+// Access checks are only recorded once tracking has been enabled with [SetContextAccessTracking].
+func ContextAccessChecked(ctx context.Context) bool {
+	if tracker, ok := ctx.Value(internal.AccessCheckCtxKey).(*internal.AccessTracker); ok {
+		return tracker.Checked()
+	}
+
+	return false
+}
+
 // SetContextRuntime sets the runtime context key to the provided runtime.
 // The provided runtime must implement all iam-runtime clients.
 //
@@ -100,12 +130,39 @@ func SetContextRuntimeAny(ctx context.Context, value any) context.Context {
 	return context.WithValue(ctx, internal.RuntimeCtxKey, value)
 }
 
-// SetContextToken sets the token context key to the provided token.
+// SetContextToken sets the credential context key to a credential for the provided token.
 func SetContextToken(ctx context.Context, value *jwt.Token) context.Context {
-	return context.WithValue(ctx, internal.TokenCtxKey, value)
+	if value == nil {
+		return SetContextCredential(ctx, nil)
+	}
+
+	return SetContextCredential(ctx, NewTokenCredential(value))
+}
+
+// SetContextCredential sets the credential context key to the provided credential.
+func SetContextCredential(ctx context.Context, value *Credential) context.Context {
+	return context.WithValue(ctx, internal.CredentialCtxKey, value)
 }
 
 // SetContextSubject sets the subject context key to the provided value.
 func SetContextSubject(ctx context.Context, value string) context.Context {
 	return context.WithValue(ctx, internal.SubjectCtxKey, value)
 }
+
+// SetContextSubjectClaims sets the subject claims context key to the provided value.
+func SetContextSubjectClaims(ctx context.Context, value map[string]any) context.Context {
+	return context.WithValue(ctx, internal.SubjectClaimsCtxKey, value)
+}
+
+// SetContextAccessTracking enables recording of access checks executed with the returned context.
+// Use [ContextAccessChecked] to determine if an access check has been executed.
+func SetContextAccessTracking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, internal.AccessCheckCtxKey, new(internal.AccessTracker))
+}
+
+// markAccessChecked records an access check was executed if tracking is enabled on the provided context.
+func markAccessChecked(ctx context.Context) {
+	if tracker, ok := ctx.Value(internal.AccessCheckCtxKey).(*internal.AccessTracker); ok {
+		tracker.Mark()
+	}
+}
@@ -0,0 +1,40 @@
+package iamruntime
+
+import (
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Credential is a credential provided by a subject.
+type Credential struct {
+	// Raw is the literal credential provided by the subject.
+	Raw string
+
+	// Token is the decoded jwt token.
+	// Token is nil for opaque credentials.
+	Token *jwt.Token
+
+	// claims memoizes decoded claims by type.
+	claims sync.Map
+}
+
+// IsOpaque returns true if the credential is not a decoded jwt token.
+func (c *Credential) IsOpaque() bool {
+	return c.Token == nil
+}
+
+// NewOpaqueCredential returns a new credential for a raw credential which is not decoded, such as an API key.
+func NewOpaqueCredential(raw string) *Credential {
+	return &Credential{
+		Raw: raw,
+	}
+}
+
+// NewTokenCredential returns a new credential for the provided decoded jwt token.
+func NewTokenCredential(token *jwt.Token) *Credential {
+	return &Credential{
+		Raw:   token.Raw,
+		Token: token,
+	}
+}
@@ -3,6 +3,10 @@ package iamruntime
 import (
 	"errors"
 	"fmt"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -21,9 +25,36 @@ var (
 	// ErrInvalidCredentials is the error returned when the provided credentials are not valid.
 	ErrInvalidCredentials = fmt.Errorf("%w: invalid credentials", AuthError)
 
+	// ErrCredentialRevoked is the error returned when the provided credential has been revoked.
+	ErrCredentialRevoked = fmt.Errorf("%w: credential revoked", ErrInvalidCredentials)
+
+	// ErrRevocationCheckFailed is the error returned when the revocation status of a credential could not be determined.
+	ErrRevocationCheckFailed = fmt.Errorf("%w: failed to check revocation", AuthError)
+
+	// ErrTokenPrevalidationFailed is the error returned when a credential is rejected by local validation.
+	ErrTokenPrevalidationFailed = fmt.Errorf("%w: local validation failed", ErrInvalidCredentials)
+
+	// ErrKeySetUnavailable is the error returned when the key set used for local validation is unavailable.
+	ErrKeySetUnavailable = fmt.Errorf("%w: key set unavailable", AuthError)
+
+	// ErrMultipleTokens is the error returned when more than one credential is provided.
+	ErrMultipleTokens = fmt.Errorf("%w: multiple tokens provided", AuthError)
+
+	// ErrSubjectMismatch is the error returned when the subject returned by the runtime does not match the token subject.
+	ErrSubjectMismatch = fmt.Errorf("%w: runtime subject does not match token subject", AuthError)
+
+	// ErrSubjectMissing is the error returned when a validated credential does not resolve to a subject.
+	ErrSubjectMissing = fmt.Errorf("%w: subject missing", AuthError)
+
 	// ErrTokenNotFound is the error returned when the token is not found in the context.
 	ErrTokenNotFound = fmt.Errorf("%w: token not found", AuthError)
 
+	// ErrForwardingNotAllowed is the error returned when a credential would be forwarded to a destination which is not allowed.
+	ErrForwardingNotAllowed = fmt.Errorf("%w: credential forwarding not allowed", AuthError)
+
+	// ErrClaimsInvalid is the error returned when the credential claims could not be decoded.
+	ErrClaimsInvalid = fmt.Errorf("%w: invalid claims", AuthError)
+
 	// AccessError is the root error for all access related errors.
 	AccessError = fmt.Errorf("%w: access", Error) //nolint:revive,stylecheck // not returned directly, but used as a root error.
 
@@ -36,12 +67,66 @@ var (
 	// ErrAccessDenied is the error returned when an access request is denied.
 	ErrAccessDenied = fmt.Errorf("%w: denied", AccessError)
 
+	// ErrInsufficientScope is the error returned when the credential does not have the required scopes.
+	ErrInsufficientScope = fmt.Errorf("%w: insufficient scope", ErrAccessDenied)
+
+	// ErrAudienceNotAllowed is the error returned when the credential audience is not allowed.
+	ErrAudienceNotAllowed = fmt.Errorf("%w: audience not allowed", ErrAccessDenied)
+
+	// ErrIssuerNotAllowed is the error returned when the credential issuer is not allowed.
+	ErrIssuerNotAllowed = fmt.Errorf("%w: issuer not allowed", ErrAccessDenied)
+
+	// ErrClaimNotAllowed is the error returned when a credential claim does not satisfy a requirement.
+	ErrClaimNotAllowed = fmt.Errorf("%w: claim not allowed", ErrAccessDenied)
+
+	// ErrAccessNotChecked is the error returned when a request completed without executing an access check.
+	ErrAccessNotChecked = fmt.Errorf("%w: not checked", AccessError)
+
 	// RelationshipError is the root error for all relationship related errors.
 	RelationshipError = fmt.Errorf("%w: relationship", Error) //nolint:revive,stylecheck // not returned directly, but used as a root error.
 
 	// ErrRelationshipRequestFailed is the error returned when a relationship request failed to execute.
 	ErrRelationshipRequestFailed = fmt.Errorf("%w: failed to execute relationship request", RelationshipError)
 
+	// ErrRelationshipIDInvalid is the error returned when a resource or subject id does not follow the id naming convention.
+	ErrRelationshipIDInvalid = fmt.Errorf("%w: invalid id", RelationshipError)
+
+	// ErrRelationInvalid is the error returned when a relationship has an invalid relation.
+	ErrRelationInvalid = fmt.Errorf("%w: invalid relation", RelationshipError)
+
+	// ErrRelationshipOperationInvalid is the error returned when a relationship write has an unknown operation.
+	ErrRelationshipOperationInvalid = fmt.Errorf("%w: invalid operation", RelationshipError)
+
+	// ErrRelationshipInvalid is the error returned when the runtime rejects a relationship request as invalid.
+	ErrRelationshipInvalid = fmt.Errorf("%w: invalid relationship", ErrRelationshipRequestFailed)
+
+	// ErrRelationshipConflict is the error returned when a relationship being created already exists.
+	ErrRelationshipConflict = fmt.Errorf("%w: relationship already exists", ErrRelationshipRequestFailed)
+
+	// ErrRelationshipNotFound is the error returned when a relationship or resource is not found.
+	ErrRelationshipNotFound = fmt.Errorf("%w: relationship not found", ErrRelationshipRequestFailed)
+
+	// ErrRelationshipPermissionDenied is the error returned when the runtime is not permitted to modify the relationship.
+	ErrRelationshipPermissionDenied = fmt.Errorf("%w: permission denied", ErrRelationshipRequestFailed)
+
+	// ErrRelationshipReconcileFailed is the error returned when the relationships of a resource were not fully reconciled.
+	ErrRelationshipReconcileFailed = fmt.Errorf("%w: reconcile failed", RelationshipError)
+
+	// ErrRelationshipCompensationFailed is the error returned when relationship writes could not be reverted.
+	ErrRelationshipCompensationFailed = fmt.Errorf("%w: compensation failed", RelationshipError)
+
+	// ErrRelationshipTxDone is the error returned when a relationship transaction has already been committed or rolled back.
+	ErrRelationshipTxDone = fmt.Errorf("%w: transaction already committed or rolled back", RelationshipError)
+
+	// ErrRelationshipBulkFailed is the error returned when one or more items of a bulk relationship write failed.
+	ErrRelationshipBulkFailed = fmt.Errorf("%w: bulk write failed", RelationshipError)
+
+	// ErrOutboxStoreFailed is the error returned when the relationship outbox store could not be read or written.
+	ErrOutboxStoreFailed = fmt.Errorf("%w: outbox store failed", RelationshipError)
+
+	// ErrOutboxEntryNotFound is the error returned when a relationship outbox entry does not exist.
+	ErrOutboxEntryNotFound = fmt.Errorf("%w: outbox entry not found", RelationshipError)
+
 	// IdentityError is the root error for all identity related errors.
 	IdentityError = fmt.Errorf("%w: identity", Error) //nolint:revive,stylecheck // not returned directly, but used as a root error.
 
@@ -51,6 +136,81 @@ var (
 	// ErrAccessTokenInvalid is the error returned when an access token returned is not valid.
 	ErrAccessTokenInvalid = fmt.Errorf("%w: invalid access token", IdentityError)
 
+	// ErrTokenSourceOptionInvalid is the error returned when a token source is configured with an invalid option.
+	ErrTokenSourceOptionInvalid = fmt.Errorf("%w: invalid token source option", IdentityError)
+
+	// ErrTokenCacheFailed is the error returned when a token cache could not be read or written.
+	ErrTokenCacheFailed = fmt.Errorf("%w: token cache failed", IdentityError)
+
 	// ErrNotReady is returned when an individual health check is not ready.
 	ErrNotReady = fmt.Errorf("%w: runtime not ready", Error)
 )
+
+// AccessDeniedError is the error returned when an access request is denied.
+// It matches [ErrAccessDenied] using [errors.Is].
+type AccessDeniedError struct {
+	// Subject is the subject which was denied access.
+	// Subject is empty if the subject is not set on the context.
+	Subject string
+
+	// Actions are the actions requested by the denied access request.
+	Actions []*authorization.AccessRequestAction
+}
+
+// Error returns the error message of [ErrAccessDenied].
+func (e *AccessDeniedError) Error() string {
+	return ErrAccessDenied.Error()
+}
+
+// Unwrap returns [ErrAccessDenied].
+func (e *AccessDeniedError) Unwrap() error {
+	return ErrAccessDenied
+}
+
+// GRPCStatus returns a PermissionDenied status.
+func (e *AccessDeniedError) GRPCStatus() *status.Status {
+	return status.New(codes.PermissionDenied, e.Error())
+}
+
+// RuntimeCallError is the error returned when a request to the runtime fails to execute.
+// It matches the iamruntime error describing the failed request, such as [ErrAccessCheckFailed],
+// as well as the error returned by the runtime using [errors.Is].
+type RuntimeCallError struct {
+	// Method is the runtime method which was called, such as CheckAccess.
+	Method string
+
+	// Code is the gRPC status code returned by the runtime.
+	// Code is [codes.Unknown] if the runtime error does not have a gRPC status.
+	Code codes.Code
+
+	// Err is the error returned by the runtime.
+	Err error
+
+	kind error
+}
+
+// Error returns the iamruntime error message followed by the runtime error message.
+func (e *RuntimeCallError) Error() string {
+	return e.kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the iamruntime error describing the failed request and the error returned by the runtime.
+func (e *RuntimeCallError) Unwrap() []error {
+	return []error{e.kind, e.Err}
+}
+
+// GRPCStatus returns a status with the code returned by the runtime.
+func (e *RuntimeCallError) GRPCStatus() *status.Status {
+	return status.New(e.Code, e.Error())
+}
+
+// NewRuntimeCallError returns a new [RuntimeCallError] for the runtime method which returned err.
+// Kind is the iamruntime error describing the failed request, such as [ErrAccessCheckFailed].
+func NewRuntimeCallError(kind error, method string, err error) *RuntimeCallError {
+	return &RuntimeCallError{
+		Method: method,
+		Code:   status.Code(err),
+		Err:    err,
+		kind:   kind,
+	}
+}
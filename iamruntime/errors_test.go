@@ -0,0 +1,90 @@
+package iamruntime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestRuntimeCallError(t *testing.T) {
+	testCases := []struct {
+		name          string
+		kind          error
+		err           error
+		expectMessage string
+		expectCode    codes.Code
+	}{
+		{
+			"status error",
+			ErrAccessCheckFailed,
+			status.Error(codes.Unavailable, "runtime unavailable"),
+			"iam-runtime error: access: failed to check access: rpc error: code = Unavailable desc = runtime unavailable",
+			codes.Unavailable,
+		},
+		{
+			"plain error",
+			fmt.Errorf("%w: create", ErrRelationshipRequestFailed),
+			grpc.ErrServerStopped,
+			"iam-runtime error: relationship: failed to execute relationship request: create: grpc: the server has been stopped",
+			codes.Unknown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var err error = NewRuntimeCallError(tc.kind, "SomeMethod", tc.err)
+
+			err = fmt.Errorf("wrapped: %w", err)
+
+			assert.ErrorIs(t, err, tc.kind, "expected error to match kind")
+			assert.ErrorIs(t, err, tc.err, "expected error to match runtime error")
+			assert.Equal(t, "wrapped: "+tc.expectMessage, err.Error(), "unexpected error message")
+
+			var callErr *RuntimeCallError
+
+			require.ErrorAs(t, err, &callErr, "expected runtime call error")
+			assert.Equal(t, "SomeMethod", callErr.Method, "unexpected method")
+			assert.Equal(t, tc.expectCode, callErr.Code, "unexpected code")
+
+			assert.Equal(t, tc.expectCode, status.Code(err), "unexpected grpc status code")
+		})
+	}
+}
+
+func TestAccessDeniedError(t *testing.T) {
+	runtime := new(mockruntime.MockRuntime)
+
+	runtime.Mock.On("CheckAccess", map[string][]string{"testten-abc123": {"action_one"}}).Return(authorization.CheckAccessResponse_RESULT_DENIED, nil)
+
+	ctx := context.Background()
+
+	ctx = SetContextRuntime(ctx, runtime)
+	ctx = SetContextCredential(ctx, NewOpaqueCredential("some credential"))
+	ctx = SetContextSubject(ctx, "some subject")
+
+	err := ContextCheckAccessTo(ctx, "testten-abc123", "action_one")
+	require.Error(t, err, "expected error to be returned")
+
+	runtime.Mock.AssertExpectations(t)
+
+	assert.ErrorIs(t, err, ErrAccessDenied, "expected access denied error")
+	assert.Equal(t, "iam-runtime error: access: denied", err.Error(), "unexpected error message")
+
+	var deniedErr *AccessDeniedError
+
+	require.True(t, errors.As(err, &deniedErr), "expected access denied error")
+	assert.Equal(t, "some subject", deniedErr.Subject, "unexpected subject")
+	assert.Equal(t, []*authorization.AccessRequestAction{{ResourceId: "testten-abc123", Action: "action_one"}}, deniedErr.Actions, "unexpected actions")
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "unexpected grpc status code")
+}
@@ -0,0 +1,271 @@
+package iamruntime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+	"google.golang.org/grpc"
+)
+
+// RelationshipPlan is the set of changes required to reconcile the relationships of a resource.
+type RelationshipPlan struct {
+	// ResourceID is the resource the relationships are from.
+	ResourceID string
+
+	// Create are the desired relationships which do not exist.
+	Create []Relationship
+
+	// Delete are the existing relationships which are not desired.
+	Delete []Relationship
+}
+
+// Empty returns true if the plan has no changes.
+func (p *RelationshipPlan) Empty() bool {
+	return len(p.Create) == 0 && len(p.Delete) == 0
+}
+
+// PlanRelationships computes the minimal set of relationships to create and delete to change the relationships
+// of a resource from old to desired. Duplicate relationships are ignored.
+func PlanRelationships(resourceID string, old, desired []Relationship) *RelationshipPlan {
+	oldSet := make(map[Relationship]struct{}, len(old))
+
+	for _, rel := range old {
+		oldSet[rel] = struct{}{}
+	}
+
+	desiredSet := make(map[Relationship]struct{}, len(desired))
+
+	for _, rel := range desired {
+		desiredSet[rel] = struct{}{}
+	}
+
+	plan := &RelationshipPlan{
+		ResourceID: resourceID,
+	}
+
+	for _, rel := range desired {
+		if _, ok := oldSet[rel]; !ok {
+			plan.Create = append(plan.Create, rel)
+
+			oldSet[rel] = struct{}{}
+		}
+	}
+
+	for _, rel := range old {
+		if _, ok := desiredSet[rel]; !ok {
+			plan.Delete = append(plan.Delete, rel)
+
+			desiredSet[rel] = struct{}{}
+		}
+	}
+
+	return plan
+}
+
+// ReconcileError is the error returned when a reconciliation plan was not fully applied.
+// It matches [ErrRelationshipReconcileFailed] and the error which stopped the reconciliation using [errors.Is].
+type ReconcileError struct {
+	// Plan is the plan which was being applied.
+	Plan *RelationshipPlan
+
+	// Created are the relationships which were created.
+	Created []Relationship
+
+	// Deleted are the relationships which were deleted.
+	Deleted []Relationship
+
+	// NotCreated are the relationships which were not created.
+	NotCreated []Relationship
+
+	// NotDeleted are the relationships which were not deleted.
+	NotDeleted []Relationship
+
+	// Err is the error which stopped the reconciliation.
+	Err error
+}
+
+// Error returns a summary of the applied and pending changes followed by the error which stopped the reconciliation.
+func (e *ReconcileError) Error() string {
+	return fmt.Sprintf("%s: %s: %d created, %d not created, %d deleted, %d not deleted: %s",
+		ErrRelationshipReconcileFailed, e.Plan.ResourceID,
+		len(e.Created), len(e.NotCreated), len(e.Deleted), len(e.NotDeleted),
+		e.Err,
+	)
+}
+
+// Unwrap returns [ErrRelationshipReconcileFailed] and the error which stopped the reconciliation.
+func (e *ReconcileError) Unwrap() []error {
+	return []error{ErrRelationshipReconcileFailed, e.Err}
+}
+
+// ReconcileOption configures [ReconcileRelationships].
+type ReconcileOption func(*reconcileOptions)
+
+type reconcileOptions struct {
+	dryRun   bool
+	callOpts []grpc.CallOption
+}
+
+// ReconcileDryRun returns the plan without applying it.
+func ReconcileDryRun() ReconcileOption {
+	return func(o *reconcileOptions) {
+		o.dryRun = true
+	}
+}
+
+// ReconcileCallOptions sets the grpc call options used for the relationship requests.
+func ReconcileCallOptions(opts ...grpc.CallOption) ReconcileOption {
+	return func(o *reconcileOptions) {
+		o.callOpts = append(o.callOpts, opts...)
+	}
+}
+
+// ReconcileRelationships changes the relationships of a resource from old to desired using the runtime in the context.
+// The plan is computed by [PlanRelationships] and returned, even if applying it fails.
+//
+// Where possible, relationships are created before the old relationships are deleted, so a failure never leaves
+// the resource with fewer relationships than it started with. Relations which may only have a single value,
+// such as parent, cannot be created while the old relationship exists. If the runtime rejects the create as a conflict,
+// the old relationships of the relations being created are deleted first, then the desired relationships are created
+// and finally the remaining old relationships are deleted. If the create then fails, the resource is left without
+// the deleted relationships, as reported by the returned error.
+//
+// If the plan is only partially applied, a [*ReconcileError] is returned describing which changes
+// were and were not applied.
+func ReconcileRelationships(ctx context.Context, resourceID string, old, desired []Relationship, opts ...ReconcileOption) (*RelationshipPlan, error) {
+	var options reconcileOptions
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	plan := PlanRelationships(resourceID, old, desired)
+
+	if err := validateRelationships(resourceID, plan.Create); err != nil {
+		return plan, err
+	}
+
+	if err := validateRelationships(resourceID, plan.Delete); err != nil {
+		return plan, err
+	}
+
+	if options.dryRun || plan.Empty() {
+		return plan, nil
+	}
+
+	err := createRelationships(ctx, resourceID, plan.Create, options.callOpts)
+	if errors.Is(err, ErrRelationshipConflict) {
+		return plan, replaceRelationships(ctx, plan, options.callOpts, err)
+	}
+
+	if err != nil {
+		return plan, &ReconcileError{
+			Plan:       plan,
+			NotCreated: plan.Create,
+			NotDeleted: plan.Delete,
+			Err:        err,
+		}
+	}
+
+	if err = deleteRelationships(ctx, resourceID, plan.Delete, options.callOpts); err != nil {
+		return plan, &ReconcileError{
+			Plan:       plan,
+			Created:    plan.Create,
+			NotDeleted: plan.Delete,
+			Err:        err,
+		}
+	}
+
+	return plan, nil
+}
+
+// replaceRelationships applies the plan after creating the relationships failed with a conflict,
+// deleting the old relationships of the relations being created before creating the desired relationships.
+// If no old relationships have the relations being created, the conflict is returned.
+func replaceRelationships(ctx context.Context, plan *RelationshipPlan, callOpts []grpc.CallOption, conflict error) error {
+	created := make(map[Relation]struct{}, len(plan.Create))
+
+	for _, rel := range plan.Create {
+		created[rel.Relation] = struct{}{}
+	}
+
+	var replaced, remaining []Relationship
+
+	for _, rel := range plan.Delete {
+		if _, ok := created[rel.Relation]; ok {
+			replaced = append(replaced, rel)
+		} else {
+			remaining = append(remaining, rel)
+		}
+	}
+
+	if len(replaced) == 0 {
+		return &ReconcileError{
+			Plan:       plan,
+			NotCreated: plan.Create,
+			NotDeleted: plan.Delete,
+			Err:        conflict,
+		}
+	}
+
+	if err := deleteRelationships(ctx, plan.ResourceID, replaced, callOpts); err != nil {
+		return &ReconcileError{
+			Plan:       plan,
+			NotCreated: plan.Create,
+			NotDeleted: plan.Delete,
+			Err:        err,
+		}
+	}
+
+	if err := createRelationships(ctx, plan.ResourceID, plan.Create, callOpts); err != nil {
+		return &ReconcileError{
+			Plan:       plan,
+			Deleted:    replaced,
+			NotCreated: plan.Create,
+			NotDeleted: remaining,
+			Err:        err,
+		}
+	}
+
+	if err := deleteRelationships(ctx, plan.ResourceID, remaining, callOpts); err != nil {
+		return &ReconcileError{
+			Plan:       plan,
+			Created:    plan.Create,
+			Deleted:    replaced,
+			NotDeleted: remaining,
+			Err:        err,
+		}
+	}
+
+	return nil
+}
+
+// createRelationships creates the relationships, if any.
+func createRelationships(ctx context.Context, resourceID string, relationships []Relationship, callOpts []grpc.CallOption) error {
+	if len(relationships) == 0 {
+		return nil
+	}
+
+	_, err := ContextCreateRelationships(ctx, &authorization.CreateRelationshipsRequest{
+		ResourceId:    resourceID,
+		Relationships: relationshipMessages(relationships),
+	}, callOpts...)
+
+	return err
+}
+
+// deleteRelationships deletes the relationships, if any.
+func deleteRelationships(ctx context.Context, resourceID string, relationships []Relationship, callOpts []grpc.CallOption) error {
+	if len(relationships) == 0 {
+		return nil
+	}
+
+	_, err := ContextDeleteRelationships(ctx, &authorization.DeleteRelationshipsRequest{
+		ResourceId:    resourceID,
+		Relationships: relationshipMessages(relationships),
+	}, callOpts...)
+
+	return err
+}
@@ -0,0 +1,293 @@
+package iamruntime
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestPlanRelationships(t *testing.T) {
+	old := []Relationship{
+		{RelationParent, "testten-root123"},
+		{RelationOwner, "idntusr-owner1"},
+		{RelationMember, "idntusr-member1"},
+		{RelationMember, "idntusr-member1"},
+	}
+
+	desired := []Relationship{
+		{RelationParent, "testten-root456"},
+		{RelationOwner, "idntusr-owner1"},
+		{RelationMember, "idntusr-member2"},
+		{RelationMember, "idntusr-member2"},
+	}
+
+	plan := PlanRelationships("testten-abc123", old, desired)
+
+	assert.Equal(t, "testten-abc123", plan.ResourceID, "unexpected resource id")
+	assert.Equal(t, []Relationship{
+		{RelationParent, "testten-root456"},
+		{RelationMember, "idntusr-member2"},
+	}, plan.Create, "unexpected create set")
+	assert.Equal(t, []Relationship{
+		{RelationParent, "testten-root123"},
+		{RelationMember, "idntusr-member1"},
+	}, plan.Delete, "unexpected delete set")
+	assert.False(t, plan.Empty(), "expected plan to have changes")
+
+	assert.True(t, PlanRelationships("testten-abc123", old, old).Empty(), "expected plan to be empty")
+}
+
+func TestReconcileRelationships(t *testing.T) {
+	old := []Relationship{
+		{RelationParent, "testten-root123"},
+		{RelationOwner, "idntusr-owner1"},
+	}
+
+	desired := []Relationship{
+		{RelationParent, "testten-root456"},
+		{RelationOwner, "idntusr-owner1"},
+	}
+
+	testCases := []struct {
+		name             string
+		desired          []Relationship
+		opts             []ReconcileOption
+		createError      error
+		deleteError      error
+		expectCreate     bool
+		expectDelete     bool
+		expectError      error
+		expectNotCreated int
+		expectNotDeleted int
+	}{
+		{
+			"reconciled",
+			desired,
+			nil,
+			nil,
+			nil,
+			true,
+			true,
+			nil,
+			0,
+			0,
+		},
+		{
+			"unchanged",
+			old,
+			nil,
+			nil,
+			nil,
+			false,
+			false,
+			nil,
+			0,
+			0,
+		},
+		{
+			"dry run",
+			desired,
+			[]ReconcileOption{ReconcileDryRun()},
+			nil,
+			nil,
+			false,
+			false,
+			nil,
+			0,
+			0,
+		},
+		{
+			"invalid id",
+			[]Relationship{{RelationParent, "root456"}},
+			nil,
+			nil,
+			nil,
+			false,
+			false,
+			ErrRelationshipIDInvalid,
+			0,
+			0,
+		},
+		{
+			"create failed",
+			desired,
+			nil,
+			grpc.ErrServerStopped,
+			nil,
+			true,
+			false,
+			ErrRelationshipRequestFailed,
+			1,
+			1,
+		},
+		{
+			"delete failed",
+			desired,
+			nil,
+			nil,
+			grpc.ErrServerStopped,
+			true,
+			true,
+			ErrRelationshipRequestFailed,
+			0,
+			1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			if tc.expectCreate {
+				runtime.Mock.On("CreateRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root456"}}).Return(tc.createError)
+			}
+
+			if tc.expectDelete {
+				runtime.Mock.On("DeleteRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root123"}}).Return(tc.deleteError)
+			}
+
+			ctx := SetContextRuntime(context.Background(), runtime)
+
+			plan, err := ReconcileRelationships(ctx, "testten-abc123", old, tc.desired, tc.opts...)
+
+			runtime.Mock.AssertExpectations(t)
+
+			require.NotNil(t, plan, "expected plan to be returned")
+
+			if tc.expectError == nil {
+				assert.NoError(t, err, "expected no error to be returned")
+
+				return
+			}
+
+			require.Error(t, err, "expected error to be returned")
+			assert.ErrorIs(t, err, tc.expectError, "unexpected error returned")
+
+			if tc.expectCreate {
+				var reconcileErr *ReconcileError
+
+				require.ErrorAs(t, err, &reconcileErr, "expected reconcile error")
+				assert.ErrorIs(t, err, ErrRelationshipReconcileFailed, "expected reconcile failed error")
+				assert.Len(t, reconcileErr.NotCreated, tc.expectNotCreated, "unexpected not created count")
+				assert.Len(t, reconcileErr.NotDeleted, tc.expectNotDeleted, "unexpected not deleted count")
+				assert.Len(t, reconcileErr.Created, len(plan.Create)-tc.expectNotCreated, "unexpected created count")
+			}
+		})
+	}
+}
+
+func TestReconcileRelationshipsReplace(t *testing.T) {
+	old := []Relationship{
+		{RelationParent, "testten-root123"},
+		{RelationMember, "idntusr-member1"},
+	}
+
+	desired := []Relationship{
+		{RelationParent, "testten-root456"},
+	}
+
+	conflict := status.Error(codes.AlreadyExists, "parent already exists")
+
+	testCases := []struct {
+		name          string
+		desired       []Relationship
+		replaceError  error
+		expectReplace bool
+		expectError   error
+		expectDeleted int
+		expectCreated int
+	}{
+		{
+			"changed parent",
+			desired,
+			nil,
+			true,
+			nil,
+			0,
+			0,
+		},
+		{
+			"replacement failed",
+			desired,
+			grpc.ErrServerStopped,
+			true,
+			ErrRelationshipRequestFailed,
+			1,
+			0,
+		},
+		{
+			"no replaced relation",
+			[]Relationship{{RelationParent, "testten-root123"}, {RelationOwner, "idntusr-owner1"}},
+			nil,
+			false,
+			ErrRelationshipConflict,
+			0,
+			0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			if tc.expectReplace {
+				runtime.Mock.On("CreateRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root456"}}).Return(conflict).Once()
+				runtime.Mock.On("DeleteRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root123"}}).Return(nil).Once()
+				runtime.Mock.On("CreateRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root456"}}).Return(tc.replaceError).Once()
+
+				if tc.replaceError == nil {
+					runtime.Mock.On("DeleteRelationships", "testten-abc123", map[string][]string{"member": {"idntusr-member1"}}).Return(nil).Once()
+				}
+			} else {
+				runtime.Mock.On("CreateRelationships", "testten-abc123", map[string][]string{"owner": {"idntusr-owner1"}}).Return(conflict).Once()
+			}
+
+			ctx := SetContextRuntime(context.Background(), runtime)
+
+			_, err := ReconcileRelationships(ctx, "testten-abc123", old, tc.desired)
+
+			runtime.Mock.AssertExpectations(t)
+
+			if tc.expectError == nil {
+				assert.NoError(t, err, "expected no error to be returned")
+
+				return
+			}
+
+			var reconcileErr *ReconcileError
+
+			require.ErrorAs(t, err, &reconcileErr, "expected reconcile error")
+			assert.ErrorIs(t, err, tc.expectError, "unexpected error returned")
+			assert.Len(t, reconcileErr.Deleted, tc.expectDeleted, "unexpected deleted count")
+			assert.Len(t, reconcileErr.Created, tc.expectCreated, "unexpected created count")
+		})
+	}
+}
+
+func ExampleReconcileRelationships() {
+	ctx := context.TODO()
+
+	old := Relate("loadbal-abc123").Parent("tnntten-root123").Owner("idntusr-owner1").Relationships()
+	desired := Relate("loadbal-abc123").Parent("tnntten-root456").Owner("idntusr-owner1").Relationships()
+
+	plan, err := ReconcileRelationships(ctx, "loadbal-abc123", old, desired, ReconcileDryRun())
+	if err != nil {
+		fmt.Println("failed to plan relationships:", err)
+
+		return
+	}
+
+	fmt.Println("create:", plan.Create)
+	fmt.Println("delete:", plan.Delete)
+	// Output:
+	// create: [{parent tnntten-root456}]
+	// delete: [{parent tnntten-root123}]
+}
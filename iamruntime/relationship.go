@@ -0,0 +1,202 @@
+package iamruntime
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+	"google.golang.org/grpc"
+)
+
+// idPattern matches ids following the naming convention of a seven character lowercase alphanumeric prefix,
+// a hyphen and a non-empty suffix, for example testten-abc123.
+var idPattern = regexp.MustCompile(`^[a-z0-9]{7}-\S+$`)
+
+// Relation is the name of a relationship from a resource to a subject.
+type Relation string
+
+const (
+	// RelationParent relates a resource to its parent resource.
+	RelationParent Relation = "parent"
+
+	// RelationOwner relates a resource to its owner.
+	RelationOwner Relation = "owner"
+
+	// RelationMember relates a resource to a member.
+	RelationMember Relation = "member"
+)
+
+// Relationship is a relation from a resource to a subject.
+type Relationship struct {
+	Relation  Relation `json:"relation"`
+	SubjectID string   `json:"subject_id"`
+}
+
+// ValidateID returns an error wrapping [ErrRelationshipIDInvalid] if the id does not follow the id naming convention.
+// Ids must have a seven character lowercase alphanumeric prefix followed by a hyphen and the id, for example testten-abc123.
+func ValidateID(id string) error {
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("%w: %q", ErrRelationshipIDInvalid, id)
+	}
+
+	return nil
+}
+
+// RelationshipBuilder builds the relationships of a resource.
+// Duplicate relationships are only included once.
+// Use [Relate] to create a new builder.
+type RelationshipBuilder struct {
+	resourceID    string
+	relationships []Relationship
+	seen          map[Relationship]struct{}
+}
+
+// Relate returns a new [RelationshipBuilder] for the provided resource id.
+func Relate(resourceID string) *RelationshipBuilder {
+	return &RelationshipBuilder{
+		resourceID: resourceID,
+		seen:       make(map[Relationship]struct{}),
+	}
+}
+
+// Parent adds a parent relationship to the provided id.
+func (b *RelationshipBuilder) Parent(id string) *RelationshipBuilder {
+	return b.Relation(RelationParent, id)
+}
+
+// Owner adds an owner relationship to the provided id.
+func (b *RelationshipBuilder) Owner(id string) *RelationshipBuilder {
+	return b.Relation(RelationOwner, id)
+}
+
+// Member adds a member relationship to each of the provided ids.
+func (b *RelationshipBuilder) Member(ids ...string) *RelationshipBuilder {
+	return b.Relation(RelationMember, ids...)
+}
+
+// Relation adds a relationship with the provided relation to each of the provided ids.
+func (b *RelationshipBuilder) Relation(relation Relation, ids ...string) *RelationshipBuilder {
+	for _, id := range ids {
+		rel := Relationship{
+			Relation:  relation,
+			SubjectID: id,
+		}
+
+		if _, ok := b.seen[rel]; ok {
+			continue
+		}
+
+		b.seen[rel] = struct{}{}
+		b.relationships = append(b.relationships, rel)
+	}
+
+	return b
+}
+
+// ResourceID returns the resource id the relationships are from.
+func (b *RelationshipBuilder) ResourceID() string {
+	return b.resourceID
+}
+
+// Relationships returns the relationships in the order they were added.
+func (b *RelationshipBuilder) Relationships() []Relationship {
+	return append([]Relationship(nil), b.relationships...)
+}
+
+// Validate returns an error if the resource id or any subject id does not follow the id naming convention,
+// or if a relationship has an empty relation.
+func (b *RelationshipBuilder) Validate() error {
+	return validateRelationships(b.resourceID, b.relationships)
+}
+
+// CreateRequest validates the relationships and returns them as a create relationships request.
+func (b *RelationshipBuilder) CreateRequest() (*authorization.CreateRelationshipsRequest, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &authorization.CreateRelationshipsRequest{
+		ResourceId:    b.resourceID,
+		Relationships: relationshipMessages(b.relationships),
+	}, nil
+}
+
+// DeleteRequest validates the relationships and returns them as a delete relationships request.
+func (b *RelationshipBuilder) DeleteRequest() (*authorization.DeleteRelationshipsRequest, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &authorization.DeleteRelationshipsRequest{
+		ResourceId:    b.resourceID,
+		Relationships: relationshipMessages(b.relationships),
+	}, nil
+}
+
+// Create validates the relationships and creates them using the runtime in the context.
+// If no relationships have been added, no request is made.
+// See [ContextCreateRelationships] for more details.
+func (b *RelationshipBuilder) Create(ctx context.Context, opts ...grpc.CallOption) error {
+	req, err := b.CreateRequest()
+	if err != nil {
+		return err
+	}
+
+	if len(req.Relationships) == 0 {
+		return nil
+	}
+
+	_, err = ContextCreateRelationships(ctx, req, opts...)
+
+	return err
+}
+
+// Delete validates the relationships and deletes them using the runtime in the context.
+// If no relationships have been added, no request is made.
+// See [ContextDeleteRelationships] for more details.
+func (b *RelationshipBuilder) Delete(ctx context.Context, opts ...grpc.CallOption) error {
+	req, err := b.DeleteRequest()
+	if err != nil {
+		return err
+	}
+
+	if len(req.Relationships) == 0 {
+		return nil
+	}
+
+	_, err = ContextDeleteRelationships(ctx, req, opts...)
+
+	return err
+}
+
+func validateRelationships(resourceID string, relationships []Relationship) error {
+	if !idPattern.MatchString(resourceID) {
+		return fmt.Errorf("%w: resource %q", ErrRelationshipIDInvalid, resourceID)
+	}
+
+	for _, rel := range relationships {
+		if rel.Relation == "" {
+			return fmt.Errorf("%w: empty relation for subject %q", ErrRelationInvalid, rel.SubjectID)
+		}
+
+		if !idPattern.MatchString(rel.SubjectID) {
+			return fmt.Errorf("%w: %s %q", ErrRelationshipIDInvalid, rel.Relation, rel.SubjectID)
+		}
+	}
+
+	return nil
+}
+
+func relationshipMessages(relationships []Relationship) []*authorization.Relationship {
+	messages := make([]*authorization.Relationship, len(relationships))
+
+	for i, rel := range relationships {
+		messages[i] = &authorization.Relationship{
+			Relation:  string(rel.Relation),
+			SubjectId: rel.SubjectID,
+		}
+	}
+
+	return messages
+}
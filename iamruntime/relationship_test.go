@@ -0,0 +1,157 @@
+package iamruntime
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestValidateID(t *testing.T) {
+	testCases := []struct {
+		id          string
+		expectValid bool
+	}{
+		{"testten-abc123", true},
+		{"loadbal-AbC_123-xyz", true},
+		{"testten-", false},
+		{"testten", false},
+		{"TestTen-abc123", false},
+		{"test-abc123", false},
+		{"testten-abc 123", false},
+		{"", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			err := ValidateID(tc.id)
+
+			if tc.expectValid {
+				assert.NoError(t, err, "expected id to be valid")
+			} else {
+				assert.ErrorIs(t, err, ErrRelationshipIDInvalid, "expected id to be invalid")
+			}
+		})
+	}
+}
+
+func TestRelationshipBuilder(t *testing.T) {
+	testCases := []struct {
+		name         string
+		builder      *RelationshipBuilder
+		requestError error
+		expectCalled map[string][]string
+		expectError  error
+	}{
+		{
+			"created",
+			Relate("testten-abc123").
+				Parent("testten-root123").
+				Owner("idntusr-owner1").
+				Member("idntusr-member1", "idntusr-member2").
+				Member("idntusr-member1"),
+			nil,
+			map[string][]string{
+				"parent": {"testten-root123"},
+				"owner":  {"idntusr-owner1"},
+				"member": {"idntusr-member1", "idntusr-member2"},
+			},
+			nil,
+		},
+		{
+			"custom relation",
+			Relate("testten-abc123").Relation("viewer", "idntgrp-group1"),
+			nil,
+			map[string][]string{
+				"viewer": {"idntgrp-group1"},
+			},
+			nil,
+		},
+		{
+			"invalid resource id",
+			Relate("abc123").Parent("testten-root123"),
+			nil,
+			nil,
+			ErrRelationshipIDInvalid,
+		},
+		{
+			"invalid subject id",
+			Relate("testten-abc123").Member("member1"),
+			nil,
+			nil,
+			ErrRelationshipIDInvalid,
+		},
+		{
+			"empty relation",
+			Relate("testten-abc123").Relation("", "idntusr-member1"),
+			nil,
+			nil,
+			ErrRelationInvalid,
+		},
+		{
+			"no relationships",
+			Relate("testten-abc123"),
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"failed",
+			Relate("testten-abc123").Parent("testten-root123"),
+			grpc.ErrServerStopped,
+			map[string][]string{
+				"parent": {"testten-root123"},
+			},
+			ErrRelationshipRequestFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		for _, op := range []string{"Create", "Delete"} {
+			t.Run(tc.name+" "+op, func(t *testing.T) {
+				runtime := new(mockruntime.MockRuntime)
+
+				if tc.expectCalled != nil {
+					runtime.Mock.On(op+"Relationships", tc.builder.ResourceID(), tc.expectCalled).Return(tc.requestError)
+				}
+
+				ctx := SetContextRuntime(context.Background(), runtime)
+
+				var err error
+
+				if op == "Create" {
+					err = tc.builder.Create(ctx)
+				} else {
+					err = tc.builder.Delete(ctx)
+				}
+
+				if tc.expectError != nil {
+					require.Error(t, err, "expected error to be returned")
+					assert.ErrorIs(t, err, tc.expectError, "unexpected error returned")
+				} else {
+					assert.NoError(t, err, "expected no error to be returned")
+				}
+
+				runtime.Mock.AssertExpectations(t)
+			})
+		}
+	}
+}
+
+func ExampleRelate() {
+	ctx := context.TODO()
+
+	err := Relate("loadbal-abc123").
+		Parent("tnntten-root123").
+		Owner("idntusr-owner1").
+		Member("idntusr-member1", "idntusr-member2").
+		Create(ctx)
+	if err != nil {
+		fmt.Println("failed to create relationships:", err)
+	}
+}
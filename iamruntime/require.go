@@ -0,0 +1,119 @@
+package iamruntime
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// MatchMode defines how required values are matched.
+type MatchMode int
+
+const (
+	// MatchAll requires all values to be present.
+	MatchAll MatchMode = iota
+
+	// MatchAny requires at least one of the values to be present.
+	MatchAny
+)
+
+// matches returns true if the provided values satisfy the required values under the match mode.
+func (m MatchMode) matches(values, required []string) bool {
+	if len(required) == 0 {
+		return true
+	}
+
+	contains := func(value string) bool {
+		return slices.Contains(values, value)
+	}
+
+	if m == MatchAny {
+		return slices.ContainsFunc(required, contains)
+	}
+
+	for _, value := range required {
+		if !contains(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RequireScopes ensures the credential in the provided context has the required scopes.
+// Scopes are read from both the scope and scp claims.
+// If the scopes do not match, an error wrapping [ErrInsufficientScope] and [ErrAccessDenied] is returned.
+//
+// No runtime request is made, however the check is recorded as an access check.
+func RequireScopes(ctx context.Context, mode MatchMode, scopes ...string) error {
+	actual, err := ContextScopes(ctx)
+	if err != nil {
+		return err
+	}
+
+	markAccessChecked(ctx)
+
+	if !mode.matches(actual, scopes) {
+		return fmt.Errorf("%w: %v", ErrInsufficientScope, scopes)
+	}
+
+	return nil
+}
+
+// RequireAudience ensures the credential in the provided context has the required audiences.
+// If the audiences do not match, an error wrapping [ErrAudienceNotAllowed] and [ErrAccessDenied] is returned.
+//
+// No runtime request is made, however the check is recorded as an access check.
+func RequireAudience(ctx context.Context, mode MatchMode, audiences ...string) error {
+	actual, err := ContextAudience(ctx)
+	if err != nil {
+		return err
+	}
+
+	markAccessChecked(ctx)
+
+	if !mode.matches(actual, audiences) {
+		return fmt.Errorf("%w: %v", ErrAudienceNotAllowed, audiences)
+	}
+
+	return nil
+}
+
+// RequireIssuer ensures the credential in the provided context was issued by one of the provided issuers.
+// If the issuer does not match, an error wrapping [ErrIssuerNotAllowed] and [ErrAccessDenied] is returned.
+//
+// No runtime request is made, however the check is recorded as an access check.
+func RequireIssuer(ctx context.Context, issuers ...string) error {
+	actual, err := ContextIssuer(ctx)
+	if err != nil {
+		return err
+	}
+
+	markAccessChecked(ctx)
+
+	if !slices.Contains(issuers, actual) {
+		return fmt.Errorf("%w: %s", ErrIssuerNotAllowed, actual)
+	}
+
+	return nil
+}
+
+// RequireClaim ensures the named claim of the credential in the provided context satisfies the predicate.
+// If the claim is not present or the predicate returns false, an error wrapping [ErrClaimNotAllowed] and [ErrAccessDenied] is returned.
+//
+// No runtime request is made, however the check is recorded as an access check.
+func RequireClaim(ctx context.Context, name string, predicate func(value any) bool) error {
+	claims, err := ContextClaims[map[string]any](ctx)
+	if err != nil {
+		return err
+	}
+
+	markAccessChecked(ctx)
+
+	value, ok := claims[name]
+	if !ok || !predicate(value) {
+		return fmt.Errorf("%w: %s", ErrClaimNotAllowed, name)
+	}
+
+	return nil
+}
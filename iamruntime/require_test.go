@@ -0,0 +1,116 @@
+package iamruntime
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequire(t *testing.T) {
+	claims := jwt.MapClaims{
+		"iss":       "https://iam.example.com",
+		"aud":       []any{"https://api.example.com", "https://other.example.com"},
+		"scope":     "lb:read lb:write",
+		"tenant_id": "tnntten-abc123",
+	}
+
+	isTenant := func(value any) bool {
+		return value == "tnntten-abc123"
+	}
+
+	testCases := []struct {
+		name        string
+		check       func(ctx context.Context) error
+		expectError error
+	}{
+		{
+			"scopes all",
+			func(ctx context.Context) error { return RequireScopes(ctx, MatchAll, "lb:read", "lb:write") },
+			nil,
+		},
+		{
+			"scopes all missing",
+			func(ctx context.Context) error { return RequireScopes(ctx, MatchAll, "lb:read", "lb:delete") },
+			ErrInsufficientScope,
+		},
+		{
+			"scopes any",
+			func(ctx context.Context) error { return RequireScopes(ctx, MatchAny, "lb:delete", "lb:write") },
+			nil,
+		},
+		{
+			"scopes any missing",
+			func(ctx context.Context) error { return RequireScopes(ctx, MatchAny, "lb:delete") },
+			ErrInsufficientScope,
+		},
+		{
+			"audience all",
+			func(ctx context.Context) error {
+				return RequireAudience(ctx, MatchAll, "https://api.example.com", "https://other.example.com")
+			},
+			nil,
+		},
+		{
+			"audience any missing",
+			func(ctx context.Context) error { return RequireAudience(ctx, MatchAny, "https://unknown.example.com") },
+			ErrAudienceNotAllowed,
+		},
+		{
+			"issuer",
+			func(ctx context.Context) error { return RequireIssuer(ctx, "https://iam.example.com") },
+			nil,
+		},
+		{
+			"issuer not allowed",
+			func(ctx context.Context) error { return RequireIssuer(ctx, "https://unknown.example.com") },
+			ErrIssuerNotAllowed,
+		},
+		{
+			"claim",
+			func(ctx context.Context) error { return RequireClaim(ctx, "tenant_id", isTenant) },
+			nil,
+		},
+		{
+			"claim not allowed",
+			func(ctx context.Context) error {
+				return RequireClaim(ctx, "tenant_id", func(any) bool { return false })
+			},
+			ErrClaimNotAllowed,
+		},
+		{
+			"claim missing",
+			func(ctx context.Context) error { return RequireClaim(ctx, "email", isTenant) },
+			ErrClaimNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			ctx = SetContextAccessTracking(ctx)
+			ctx = SetContextToken(ctx, &jwt.Token{Claims: claims})
+
+			err := tc.check(ctx)
+
+			if tc.expectError != nil {
+				require.Error(t, err, "expected error to be returned")
+				assert.ErrorIs(t, err, tc.expectError, "unexpected error returned")
+				assert.ErrorIs(t, err, ErrAccessDenied, "expected access denied error")
+			} else {
+				assert.NoError(t, err, "expected no error to be returned")
+			}
+
+			assert.True(t, ContextAccessChecked(ctx), "expected access check to be recorded")
+		})
+	}
+}
+
+func TestRequireTokenNotFound(t *testing.T) {
+	err := RequireScopes(context.Background(), MatchAll, "lb:read")
+
+	assert.ErrorIs(t, err, ErrTokenNotFound, "unexpected error returned")
+}
@@ -0,0 +1,22 @@
+package iamruntime
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// RevocationChecker determines whether a credential has been revoked.
+// Revocation is checked before the credential is validated by the runtime.
+type RevocationChecker interface {
+	// IsRevoked returns true if the credential has been revoked.
+	IsRevoked(ctx context.Context, credential *Credential) (bool, error)
+}
+
+// HashCredential returns the hex encoded sha256 hash of the raw credential.
+// Revocation checkers use this hash to revoke individual credentials without storing them.
+func HashCredential(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,224 @@
+package iamruntime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+	"google.golang.org/grpc"
+)
+
+// RelationshipOperation is the operation of a relationship write.
+type RelationshipOperation string
+
+const (
+	// RelationshipCreate creates relationships.
+	RelationshipCreate RelationshipOperation = "create"
+
+	// RelationshipDelete deletes relationships.
+	RelationshipDelete RelationshipOperation = "delete"
+)
+
+// RelationshipWrite is a create or delete of relationships from a resource.
+type RelationshipWrite struct {
+	Operation     RelationshipOperation `json:"operation"`
+	ResourceID    string                `json:"resource_id"`
+	Relationships []Relationship        `json:"relationships"`
+}
+
+// Inverse returns the write which reverts this write.
+func (w RelationshipWrite) Inverse() RelationshipWrite {
+	inverse := w
+
+	switch w.Operation {
+	case RelationshipCreate:
+		inverse.Operation = RelationshipDelete
+	case RelationshipDelete:
+		inverse.Operation = RelationshipCreate
+	}
+
+	return inverse
+}
+
+// Validate returns an error if the operation is unknown or the relationships are not valid.
+// See [RelationshipBuilder.Validate] for more details.
+func (w RelationshipWrite) Validate() error {
+	switch w.Operation {
+	case RelationshipCreate, RelationshipDelete:
+	default:
+		return fmt.Errorf("%w: %q", ErrRelationshipOperationInvalid, w.Operation)
+	}
+
+	return validateRelationships(w.ResourceID, w.Relationships)
+}
+
+// Apply executes the write using the runtime in the context.
+func (w RelationshipWrite) Apply(ctx context.Context, opts ...grpc.CallOption) error {
+	switch w.Operation {
+	case RelationshipCreate:
+		_, err := ContextCreateRelationships(ctx, &authorization.CreateRelationshipsRequest{
+			ResourceId:    w.ResourceID,
+			Relationships: relationshipMessages(w.Relationships),
+		}, opts...)
+
+		return err
+	case RelationshipDelete:
+		_, err := ContextDeleteRelationships(ctx, &authorization.DeleteRelationshipsRequest{
+			ResourceId:    w.ResourceID,
+			Relationships: relationshipMessages(w.Relationships),
+		}, opts...)
+
+		return err
+	default:
+		return fmt.Errorf("%w: %q", ErrRelationshipOperationInvalid, w.Operation)
+	}
+}
+
+// CompensationError is the error returned when a [RelationshipTx] rollback fails to revert writes.
+// It matches [ErrRelationshipCompensationFailed] and the errors returned by the runtime using [errors.Is].
+type CompensationError struct {
+	// Failed are the writes which could not be reverted.
+	Failed []RelationshipWrite
+
+	// Err is the joined errors of the failed compensating writes.
+	Err error
+}
+
+// Error returns the number of writes which could not be reverted followed by the errors.
+func (e *CompensationError) Error() string {
+	return fmt.Sprintf("%s: %d writes not reverted: %s", ErrRelationshipCompensationFailed, len(e.Failed), e.Err)
+}
+
+// Unwrap returns [ErrRelationshipCompensationFailed] and the errors of the failed compensating writes.
+func (e *CompensationError) Unwrap() []error {
+	return []error{ErrRelationshipCompensationFailed, e.Err}
+}
+
+// RelationshipTx records the relationship writes made within a unit of work so they can be reverted.
+//
+// Writes made through the transaction are applied immediately.
+// [RelationshipTx.Rollback] reverts the successful writes in reverse order and [RelationshipTx.Commit] discards them.
+// Once committed or rolled back, the transaction can no longer be used.
+//
+// A RelationshipTx is safe for concurrent use.
+type RelationshipTx struct {
+	opts []grpc.CallOption
+
+	mu      sync.Mutex
+	journal []RelationshipWrite
+	done    bool
+}
+
+// Create validates the relationships of the builder and creates them, recording the write.
+func (tx *RelationshipTx) Create(ctx context.Context, relationships *RelationshipBuilder) error {
+	return tx.write(ctx, RelationshipCreate, relationships)
+}
+
+// Delete validates the relationships of the builder and deletes them, recording the write.
+func (tx *RelationshipTx) Delete(ctx context.Context, relationships *RelationshipBuilder) error {
+	return tx.write(ctx, RelationshipDelete, relationships)
+}
+
+func (tx *RelationshipTx) write(ctx context.Context, operation RelationshipOperation, relationships *RelationshipBuilder) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrRelationshipTxDone
+	}
+
+	if err := relationships.Validate(); err != nil {
+		return err
+	}
+
+	write := RelationshipWrite{
+		Operation:     operation,
+		ResourceID:    relationships.ResourceID(),
+		Relationships: relationships.Relationships(),
+	}
+
+	if len(write.Relationships) == 0 {
+		return nil
+	}
+
+	if err := write.Apply(ctx, tx.opts...); err != nil {
+		return err
+	}
+
+	tx.journal = append(tx.journal, write)
+
+	return nil
+}
+
+// Writes returns the writes recorded by the transaction in the order they were applied.
+func (tx *RelationshipTx) Writes() []RelationshipWrite {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	return slices.Clone(tx.journal)
+}
+
+// Commit discards the recorded writes.
+// [ErrRelationshipTxDone] is returned if the transaction has already been committed or rolled back.
+func (tx *RelationshipTx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrRelationshipTxDone
+	}
+
+	tx.done = true
+	tx.journal = nil
+
+	return nil
+}
+
+// Rollback reverts the recorded writes in reverse order using the runtime in the context.
+// Every write is attempted even if an earlier compensating write fails.
+// If any write could not be reverted, a [*CompensationError] is returned.
+// [ErrRelationshipTxDone] is returned if the transaction has already been committed or rolled back.
+func (tx *RelationshipTx) Rollback(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrRelationshipTxDone
+	}
+
+	tx.done = true
+
+	var (
+		failed []RelationshipWrite
+		errs   []error
+	)
+
+	for _, write := range slices.Backward(tx.journal) {
+		if err := write.Inverse().Apply(ctx, tx.opts...); err != nil {
+			failed = append(failed, write)
+			errs = append(errs, err)
+		}
+	}
+
+	tx.journal = nil
+
+	if len(failed) != 0 {
+		return &CompensationError{
+			Failed: failed,
+			Err:    errors.Join(errs...),
+		}
+	}
+
+	return nil
+}
+
+// NewRelationshipTx returns a new [RelationshipTx].
+// The provided grpc call options are used for all writes, including compensating writes.
+func NewRelationshipTx(opts ...grpc.CallOption) *RelationshipTx {
+	return &RelationshipTx{
+		opts: opts,
+	}
+}
@@ -0,0 +1,137 @@
+package iamruntime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestRelationshipTx(t *testing.T) {
+	created := map[string][]string{"parent": {"testten-root123"}}
+	deleted := map[string][]string{"owner": {"idntusr-owner1"}}
+
+	testCases := []struct {
+		name              string
+		commit            bool
+		compensateError   error
+		expectError       error
+		expectCompensated bool
+	}{
+		{
+			"committed",
+			true,
+			nil,
+			nil,
+			false,
+		},
+		{
+			"rolled back",
+			false,
+			nil,
+			nil,
+			true,
+		},
+		{
+			"compensation failed",
+			false,
+			grpc.ErrServerStopped,
+			ErrRelationshipCompensationFailed,
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			runtime.Mock.On("CreateRelationships", "testten-abc123", created).Return(nil).Once()
+			deleteCall := runtime.Mock.On("DeleteRelationships", "testten-abc123", deleted).Return(nil).Once()
+
+			if tc.expectCompensated {
+				// Compensating writes are made in reverse order: the delete is reverted first.
+				revertDelete := runtime.Mock.On("CreateRelationships", "testten-abc123", deleted).Return(tc.compensateError).Once().NotBefore(deleteCall)
+				runtime.Mock.On("DeleteRelationships", "testten-abc123", created).Return(nil).Once().NotBefore(revertDelete)
+			}
+
+			ctx := SetContextRuntime(context.Background(), runtime)
+
+			tx := NewRelationshipTx()
+
+			require.NoError(t, tx.Create(ctx, Relate("testten-abc123").Parent("testten-root123")))
+			require.NoError(t, tx.Delete(ctx, Relate("testten-abc123").Owner("idntusr-owner1")))
+
+			assert.Len(t, tx.Writes(), 2, "unexpected number of recorded writes")
+
+			var err error
+
+			if tc.commit {
+				err = tx.Commit()
+			} else {
+				err = tx.Rollback(ctx)
+			}
+
+			if tc.expectError != nil {
+				require.Error(t, err, "expected error to be returned")
+				assert.ErrorIs(t, err, tc.expectError, "unexpected error returned")
+				assert.ErrorIs(t, err, grpc.ErrServerStopped, "expected runtime error to be returned")
+
+				var compensationErr *CompensationError
+
+				require.True(t, errors.As(err, &compensationErr), "expected compensation error")
+				require.Len(t, compensationErr.Failed, 1, "unexpected number of failed writes")
+				assert.Equal(t, RelationshipDelete, compensationErr.Failed[0].Operation, "unexpected failed write")
+			} else {
+				assert.NoError(t, err, "expected no error to be returned")
+			}
+
+			assert.Empty(t, tx.Writes(), "expected journal to be discarded")
+
+			assert.ErrorIs(t, tx.Rollback(ctx), ErrRelationshipTxDone, "expected transaction to be done")
+			assert.ErrorIs(t, tx.Create(ctx, Relate("testten-abc123").Parent("testten-root123")), ErrRelationshipTxDone, "expected transaction to be done")
+
+			runtime.Mock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRelationshipTxFailedWrite(t *testing.T) {
+	runtime := new(mockruntime.MockRuntime)
+
+	runtime.Mock.On("CreateRelationships", "testten-abc123", mock.Anything).Return(grpc.ErrServerStopped)
+
+	ctx := SetContextRuntime(context.Background(), runtime)
+
+	tx := NewRelationshipTx()
+
+	err := tx.Create(ctx, Relate("testten-abc123").Parent("testten-root123"))
+	assert.ErrorIs(t, err, ErrRelationshipRequestFailed, "expected request error")
+
+	assert.Empty(t, tx.Writes(), "expected failed write not to be recorded")
+	assert.NoError(t, tx.Rollback(ctx), "expected no compensating writes")
+
+	runtime.Mock.AssertExpectations(t)
+}
+
+func ExampleRelationshipTx() {
+	ctx := context.TODO()
+
+	tx := NewRelationshipTx()
+
+	// Rollback reverts the relationships if the resource is not committed.
+	defer tx.Rollback(ctx) //nolint:errcheck // rollback after commit returns ErrRelationshipTxDone.
+
+	if err := tx.Create(ctx, Relate("loadbal-abc123").Parent("tnntten-root123")); err != nil {
+		return
+	}
+
+	// Insert the resource into the database, returning on failure.
+
+	_ = tx.Commit()
+}
@@ -0,0 +1,18 @@
+package internal
+
+import "sync/atomic"
+
+// AccessTracker records whether an access check was executed.
+type AccessTracker struct {
+	checked atomic.Bool
+}
+
+// Mark records an access check was executed.
+func (t *AccessTracker) Mark() {
+	t.checked.Store(true)
+}
+
+// Checked returns true if an access check has been executed.
+func (t *AccessTracker) Checked() bool {
+	return t.checked.Load()
+}
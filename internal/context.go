@@ -1,18 +1,26 @@
 package internal
 
 type (
-	runtimeCtxKey struct{}
-	tokenCtxKey   struct{}
-	subjectCtxKey struct{}
+	runtimeCtxKey       struct{}
+	credentialCtxKey    struct{}
+	subjectCtxKey       struct{}
+	subjectClaimsCtxKey struct{}
+	accessCheckCtxKey   struct{}
 )
 
 var (
 	// RuntimeCtxKey is the context key used to retrieve the iam-runtime from the context.
 	RuntimeCtxKey = runtimeCtxKey{}
 
-	// TokenCtxKey is the context key used to retrieve the decoded jwt token from a context.
-	TokenCtxKey = tokenCtxKey{}
+	// CredentialCtxKey is the context key used to retrieve the credential from a context.
+	CredentialCtxKey = credentialCtxKey{}
 
 	// SubjectCtxKey is the context key used to retrieve just the subject from a context.
 	SubjectCtxKey = subjectCtxKey{}
+
+	// SubjectClaimsCtxKey is the context key used to retrieve the subject claims returned by the runtime from a context.
+	SubjectClaimsCtxKey = subjectClaimsCtxKey{}
+
+	// AccessCheckCtxKey is the context key used to retrieve the access check tracker from a context.
+	AccessCheckCtxKey = accessCheckCtxKey{}
 )
@@ -2,31 +2,45 @@ package internal
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 )
 
-// ErrInvalidAuthToken is the error returned when the auth token is not the expected value.
-var ErrInvalidAuthToken = errors.New("invalid auth token")
+var (
+	// ErrInvalidAuthToken is the error returned when the auth token is not the expected value.
+	ErrInvalidAuthToken = errors.New("invalid auth token")
 
-const (
-	authHeader   = "Authorization"
-	bearerPrefix = "Bearer "
+	// ErrMalformedAuthHeader is the error returned when an auth header is provided but does not contain a token.
+	ErrMalformedAuthHeader = errors.New("malformed auth header")
 )
 
-// GetBearerToken parses the Authorization header returning just the Bearer token without the Bearer prefix.
-func GetBearerToken(req *http.Request) (string, error) {
-	authHeader := strings.TrimSpace(req.Header.Get(authHeader))
+// GetHeaderTokens returns the values of the provided header with the prefix removed.
+// The prefix is matched case insensitively. Empty values are skipped.
+// An error is returned if a value does not start with the prefix or has no token after the prefix,
+// so malformed credentials are not mistaken for missing credentials.
+func GetHeaderTokens(req *http.Request, header, prefix string) ([]string, error) {
+	var tokens []string
 
-	if len(authHeader) <= len(bearerPrefix) {
-		return "", ErrInvalidAuthToken
-	}
+	for _, value := range req.Header.Values(header) {
+		value = strings.TrimSpace(value)
 
-	if !strings.EqualFold(authHeader[:len(bearerPrefix)], bearerPrefix) {
-		return "", ErrInvalidAuthToken
-	}
+		if value == "" {
+			continue
+		}
+
+		if len(value) < len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+			return nil, fmt.Errorf("%w: %s header does not start with %q", ErrMalformedAuthHeader, header, strings.TrimSpace(prefix))
+		}
 
-	token := authHeader[len(bearerPrefix):]
+		token := strings.TrimSpace(value[len(prefix):])
+
+		if token == "" {
+			return nil, fmt.Errorf("%w: %s header has no token", ErrMalformedAuthHeader, header)
+		}
+
+		tokens = append(tokens, token)
+	}
 
-	return token, nil
+	return tokens, nil
 }
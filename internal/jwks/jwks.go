@@ -0,0 +1,254 @@
+// Package jwks fetches and caches JSON Web Key Sets discovered through OIDC discovery.
+package jwks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+var (
+	// ErrFetchFailed is the error returned when the discovery document or key set could not be fetched.
+	ErrFetchFailed = errors.New("failed to fetch key set")
+
+	// ErrKeyNotFound is the error returned when the requested key is not in the key set.
+	ErrKeyNotFound = errors.New("key not found")
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+
+	// defaultTimeout is the timeout of the default client used to fetch the discovery document and key set.
+	defaultTimeout = 10 * time.Second
+)
+
+// KeySet fetches and caches the public keys of a JSON Web Key Set.
+//
+// Keys are fetched on first use and refreshed in the background once the refresh interval has elapsed,
+// the previously fetched keys are used until the refresh completes.
+// When a requested key is not found, the key set is refetched to support key rotation,
+// but no more often than the minimum refresh interval.
+// If a refresh fails, the previously fetched keys continue to be used.
+//
+// Keys are fetched without holding the lock used to look up keys, so lookups of known keys
+// do not wait for a refresh. Concurrent refreshes are coalesced into a single fetch.
+// Fetches are not canceled with the context of the lookup which started them.
+type KeySet struct {
+	client             *http.Client
+	issuer             string
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+
+	// refreshMu serializes fetches of the key set.
+	refreshMu sync.Mutex
+
+	mu         sync.Mutex
+	uri        string
+	keys       map[string]any
+	fetchedAt  time.Time
+	err        error
+	refreshing bool
+}
+
+// Key returns the public key with the provided key id.
+// If the key id is empty and the key set contains a single key, that key is returned.
+//
+// If the key is not found, an error wrapping [ErrKeyNotFound] is returned, even if refetching the key set failed.
+// An error wrapping [ErrFetchFailed] is only returned if no keys have been fetched.
+func (s *KeySet) Key(ctx context.Context, kid string) (any, error) {
+	keys, fetchedAt, err := s.state()
+
+	now := time.Now()
+	elapsed := now.Sub(fetchedAt)
+
+	switch {
+	case keys == nil && (fetchedAt.IsZero() || elapsed >= min(s.refreshInterval, s.minRefreshInterval)):
+		keys, fetchedAt, err = s.refresh(ctx, fetchedAt)
+	case keys != nil && elapsed >= s.refreshInterval:
+		s.refreshBackground(ctx, fetchedAt)
+	}
+
+	if keys == nil {
+		return nil, err
+	}
+
+	if key, ok := lookup(keys, kid); ok {
+		return key, nil
+	}
+
+	if now.Sub(fetchedAt) >= s.minRefreshInterval {
+		keys, _, err = s.refresh(ctx, fetchedAt)
+
+		if key, ok := lookup(keys, kid); ok {
+			return key, nil
+		}
+
+		if err != nil {
+			// The key is unknown whether or not the refresh failed, so the credential is rejected
+			// rather than failing the request.
+			return nil, fmt.Errorf("%w: %s: refresh failed: %s", ErrKeyNotFound, kid, err.Error())
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+}
+
+func lookup(keys map[string]any, kid string) (any, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+
+	key, ok := keys[kid]
+
+	return key, ok
+}
+
+// state returns the current keys, when they were last fetched and the error of the last fetch.
+func (s *KeySet) state() (map[string]any, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.keys, s.fetchedAt, s.err
+}
+
+// refreshBackground refreshes the key set in a new goroutine, unless a background refresh is already running.
+func (s *KeySet) refreshBackground(ctx context.Context, since time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refreshing {
+		return
+	}
+
+	s.refreshing = true
+
+	go func() {
+		s.refresh(ctx, since) //nolint:errcheck // the result is stored in the key set.
+
+		s.mu.Lock()
+		s.refreshing = false
+		s.mu.Unlock()
+	}()
+}
+
+// refresh fetches the key set, unless it has been fetched since the provided time by a concurrent refresh.
+// The current state is returned, see [KeySet.state].
+// The fetch time is updated even if the refresh fails to avoid retrying on every request.
+func (s *KeySet) refresh(ctx context.Context, since time.Time) (map[string]any, time.Time, error) {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	s.mu.Lock()
+	uri, fetchedAt := s.uri, s.fetchedAt
+	s.mu.Unlock()
+
+	if fetchedAt.After(since) {
+		return s.state()
+	}
+
+	// The fetch result is shared with other lookups, so it is not canceled with the caller's context.
+	uri, keys, err := s.fetch(context.WithoutCancel(ctx), uri)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.uri = uri
+	s.fetchedAt = time.Now()
+	s.err = err
+
+	if err == nil {
+		s.keys = keys
+	}
+
+	return s.keys, s.fetchedAt, s.err
+}
+
+// fetch fetches the key set, discovering the key set uri if uri is empty.
+// The key set uri is returned, even if fetching the key set fails.
+func (s *KeySet) fetch(ctx context.Context, uri string) (string, map[string]any, error) {
+	if uri == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+
+		if err := s.get(ctx, strings.TrimSuffix(s.issuer, "/")+discoveryPath, &discovery); err != nil {
+			return "", nil, err
+		}
+
+		if discovery.JWKSURI == "" {
+			return "", nil, fmt.Errorf("%w: discovery document missing jwks_uri", ErrFetchFailed)
+		}
+
+		uri = discovery.JWKSURI
+	}
+
+	var keySet jose.JSONWebKeySet
+
+	if err := s.get(ctx, uri, &keySet); err != nil {
+		return uri, nil, err
+	}
+
+	keys := make(map[string]any, len(keySet.Keys))
+
+	for _, key := range keySet.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		keys[key.KeyID] = key.Public().Key
+	}
+
+	return uri, keys, nil
+}
+
+func (s *KeySet) get(ctx context.Context, uri string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s: unexpected status code %d", ErrFetchFailed, uri, resp.StatusCode)
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrFetchFailed, uri, err)
+	}
+
+	return nil
+}
+
+// NewKeySet creates a new KeySet.
+// If uri is empty, the key set uri is discovered from the issuer's OIDC discovery document.
+// If client is nil, a client with a 10 second timeout is used.
+func NewKeySet(client *http.Client, issuer, uri string, refreshInterval, minRefreshInterval time.Duration) *KeySet {
+	if client == nil {
+		client = &http.Client{
+			Timeout: defaultTimeout,
+		}
+	}
+
+	return &KeySet{
+		client:             client,
+		issuer:             issuer,
+		uri:                uri,
+		refreshInterval:    refreshInterval,
+		minRefreshInterval: minRefreshInterval,
+	}
+}
@@ -0,0 +1,298 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer serves an OIDC discovery document and a key set which may be rotated or fail.
+type testServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    []jose.JSONWebKey
+	failing bool
+	block   chan struct{}
+
+	fetches atomic.Int32
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	srv := &testServer{}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"jwks_uri": srv.URL + "/jwks.json"}) //nolint:errcheck // test response.
+	})
+
+	mux.HandleFunc("/jwks.json", func(w http.ResponseWriter, _ *http.Request) {
+		srv.fetches.Add(1)
+
+		srv.mu.Lock()
+		keys, failing, block := srv.keys, srv.failing, srv.block
+		srv.mu.Unlock()
+
+		if block != nil {
+			<-block
+		}
+
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: keys}) //nolint:errcheck // test response.
+	})
+
+	srv.Server = httptest.NewServer(mux)
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// rotate replaces the served keys with new keys with the provided key ids.
+func (s *testServer) rotate(t *testing.T, kids ...string) map[string]ed25519.PublicKey {
+	t.Helper()
+
+	public := make(map[string]ed25519.PublicKey, len(kids))
+	keys := make([]jose.JSONWebKey, 0, len(kids))
+
+	for _, kid := range kids {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err, "unexpected error generating key")
+
+		public[kid] = pub
+		keys = append(keys, jose.JSONWebKey{Key: pub, KeyID: kid, Algorithm: "EdDSA", Use: "sig"})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = keys
+
+	return public
+}
+
+func (s *testServer) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failing = failing
+}
+
+func (s *testServer) setBlock(block chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.block = block
+}
+
+func TestKeySetRotation(t *testing.T) {
+	ctx := context.Background()
+
+	srv := newTestServer(t)
+
+	first := srv.rotate(t, "first")
+
+	keys := NewKeySet(nil, srv.URL, "", time.Hour, 0)
+
+	key, err := keys.Key(ctx, "first")
+	require.NoError(t, err, "unexpected error getting key")
+	assert.Equal(t, first["first"], key, "unexpected key")
+
+	key, err = keys.Key(ctx, "")
+	require.NoError(t, err, "unexpected error getting key without key id")
+	assert.Equal(t, first["first"], key, "expected single key to be returned without key id")
+
+	second := srv.rotate(t, "second")
+
+	key, err = keys.Key(ctx, "second")
+	require.NoError(t, err, "unexpected error getting rotated key")
+	assert.Equal(t, second["second"], key, "unexpected rotated key")
+
+	assert.Equal(t, int32(2), srv.fetches.Load(), "expected key set to be refetched once")
+}
+
+func TestKeySetMinRefreshInterval(t *testing.T) {
+	ctx := context.Background()
+
+	srv := newTestServer(t)
+
+	srv.rotate(t, "first")
+
+	keys := NewKeySet(nil, srv.URL, "", time.Hour, time.Hour)
+
+	_, err := keys.Key(ctx, "first")
+	require.NoError(t, err, "unexpected error getting key")
+
+	srv.rotate(t, "second")
+
+	for range 3 {
+		_, err = keys.Key(ctx, "second")
+		assert.ErrorIs(t, err, ErrKeyNotFound, "expected key not to be found before the minimum refresh interval")
+	}
+
+	assert.Equal(t, int32(1), srv.fetches.Load(), "expected key set not to be refetched")
+}
+
+func TestKeySetUnknownKey(t *testing.T) {
+	ctx := context.Background()
+
+	srv := newTestServer(t)
+
+	srv.rotate(t, "first")
+
+	keys := NewKeySet(nil, srv.URL, "", time.Hour, 0)
+
+	_, err := keys.Key(ctx, "first")
+	require.NoError(t, err, "unexpected error getting key")
+
+	_, err = keys.Key(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrKeyNotFound, "expected key not to be found")
+	assert.NotErrorIs(t, err, ErrFetchFailed, "expected unknown key not to be a fetch error")
+
+	assert.Equal(t, int32(2), srv.fetches.Load(), "expected key set to be refetched for the unknown key")
+}
+
+func TestKeySetRefreshFailed(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("initial fetch", func(t *testing.T) {
+		srv := newTestServer(t)
+
+		srv.setFailing(true)
+
+		keys := NewKeySet(nil, srv.URL, "", time.Hour, time.Hour)
+
+		_, err := keys.Key(ctx, "first")
+		assert.ErrorIs(t, err, ErrFetchFailed, "expected fetch error without keys")
+	})
+
+	t.Run("refetch", func(t *testing.T) {
+		srv := newTestServer(t)
+
+		first := srv.rotate(t, "first")
+
+		keys := NewKeySet(nil, srv.URL, "", time.Hour, 0)
+
+		_, err := keys.Key(ctx, "first")
+		require.NoError(t, err, "unexpected error getting key")
+
+		srv.setFailing(true)
+
+		_, err = keys.Key(ctx, "unknown")
+		assert.ErrorIs(t, err, ErrKeyNotFound, "expected unknown key after failed refresh")
+		assert.NotErrorIs(t, err, ErrFetchFailed, "expected unknown key not to be a fetch error")
+
+		key, err := keys.Key(ctx, "first")
+		require.NoError(t, err, "expected previously fetched keys to be used")
+		assert.Equal(t, first["first"], key, "unexpected key")
+	})
+}
+
+func TestKeySetLookupDuringRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	srv := newTestServer(t)
+
+	first := srv.rotate(t, "first")
+
+	keys := NewKeySet(nil, srv.URL, "", time.Hour, 0)
+
+	_, err := keys.Key(ctx, "first")
+	require.NoError(t, err, "unexpected error getting key")
+
+	block := make(chan struct{})
+
+	srv.setBlock(block)
+
+	refreshed := make(chan error)
+
+	go func() {
+		_, err := keys.Key(ctx, "unknown")
+
+		refreshed <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		return srv.fetches.Load() == 2
+	}, time.Second, time.Millisecond, "expected key set to be refetched")
+
+	key, err := keys.Key(ctx, "first")
+	require.NoError(t, err, "expected known key to be returned while refreshing")
+	assert.Equal(t, first["first"], key, "unexpected key")
+
+	close(block)
+
+	assert.ErrorIs(t, <-refreshed, ErrKeyNotFound, "expected unknown key")
+}
+
+func TestKeySetBackgroundRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	srv := newTestServer(t)
+
+	first := srv.rotate(t, "first")
+
+	keys := NewKeySet(nil, srv.URL, "", time.Nanosecond, time.Hour)
+
+	_, err := keys.Key(ctx, "first")
+	require.NoError(t, err, "unexpected error getting key")
+
+	block := make(chan struct{})
+
+	srv.setBlock(block)
+
+	for range 3 {
+		key, err := keys.Key(ctx, "first")
+		require.NoError(t, err, "expected known key to be returned while refreshing")
+		assert.Equal(t, first["first"], key, "unexpected key")
+	}
+
+	require.Eventually(t, func() bool {
+		return srv.fetches.Load() == 2
+	}, time.Second, time.Millisecond, "expected key set to be refreshed in the background")
+
+	second := srv.rotate(t, "second")
+
+	close(block)
+
+	require.Eventually(t, func() bool {
+		key, err := keys.Key(ctx, "second")
+
+		return err == nil && assert.ObjectsAreEqual(second["second"], key)
+	}, time.Second, time.Millisecond, "expected refreshed key to be returned")
+}
+
+func TestKeySetCanceledContext(t *testing.T) {
+	srv := newTestServer(t)
+
+	srv.rotate(t, "first")
+
+	keys := NewKeySet(nil, srv.URL, "", time.Hour, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cancel()
+
+	key, err := keys.Key(ctx, "first")
+	require.NoError(t, err, "expected key set to be fetched with a canceled context")
+	assert.NotNil(t, key, "expected key to be returned")
+}
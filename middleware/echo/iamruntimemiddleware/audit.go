@@ -0,0 +1,216 @@
+package iamruntimemiddleware
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+// StrictAccessCheckMode defines how requests which complete successfully without an access check are handled.
+type StrictAccessCheckMode int
+
+const (
+	// StrictAccessCheckDisabled takes no action on requests which complete without an access check.
+	StrictAccessCheckDisabled StrictAccessCheckMode = iota
+
+	// StrictAccessCheckLog logs an error for requests which complete successfully without an access check.
+	StrictAccessCheckLog
+
+	// StrictAccessCheckError replaces successful responses which did not execute an access check with an internal server error.
+	// Responses are buffered until the handler returns, so this mode is intended for non-production environments
+	// and only takes effect if [Config.Development] is set. Otherwise it behaves as [StrictAccessCheckLog].
+	StrictAccessCheckError
+)
+
+// RouteAccessReport summarizes the access checks executed by successful requests to a route.
+type RouteAccessReport struct {
+	// Method is the request method of the route.
+	Method string `json:"method"`
+
+	// Path is the registered path of the route.
+	Path string `json:"path"`
+
+	// Checked is the number of successful requests which executed an access check.
+	Checked uint64 `json:"checked"`
+
+	// Unchecked is the number of successful requests which completed without an access check.
+	Unchecked uint64 `json:"unchecked"`
+
+	// LastUnchecked is when the most recent request completed without an access check.
+	LastUnchecked time.Time `json:"last_unchecked,omitempty"`
+}
+
+type routeKey struct {
+	method string
+	path   string
+}
+
+// AccessAudit records whether successful requests executed an access check.
+// Access checks executed through [CheckAccess], [CheckAccessTo] or their context variants are recorded,
+// as are claim requirements such as [RequireScopes].
+//
+// Set [Config.AccessAudit] to enable recording.
+type AccessAudit struct {
+	mu     sync.Mutex
+	routes map[routeKey]*RouteAccessReport
+}
+
+func (a *AccessAudit) record(method, path string, checked bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.routes == nil {
+		a.routes = make(map[routeKey]*RouteAccessReport)
+	}
+
+	key := routeKey{method, path}
+
+	report, ok := a.routes[key]
+	if !ok {
+		report = &RouteAccessReport{
+			Method: method,
+			Path:   path,
+		}
+
+		a.routes[key] = report
+	}
+
+	if checked {
+		report.Checked++
+	} else {
+		report.Unchecked++
+		report.LastUnchecked = time.Now()
+	}
+}
+
+// Routes returns a report for every route which has completed a successful request.
+// Reports are sorted by path and method.
+func (a *AccessAudit) Routes() []RouteAccessReport {
+	return a.reports(func(RouteAccessReport) bool { return true })
+}
+
+// UncheckedRoutes returns a report for every route which has completed a successful request without an access check.
+// Reports are sorted by path and method.
+func (a *AccessAudit) UncheckedRoutes() []RouteAccessReport {
+	return a.reports(func(report RouteAccessReport) bool { return report.Unchecked != 0 })
+}
+
+func (a *AccessAudit) reports(include func(RouteAccessReport) bool) []RouteAccessReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	reports := make([]RouteAccessReport, 0, len(a.routes))
+
+	for _, report := range a.routes {
+		if include(*report) {
+			reports = append(reports, *report)
+		}
+	}
+
+	slices.SortFunc(reports, func(a, b RouteAccessReport) int {
+		return cmp.Or(cmp.Compare(a.Path, b.Path), cmp.Compare(a.Method, b.Method))
+	})
+
+	return reports
+}
+
+// Reset clears all recorded reports.
+func (a *AccessAudit) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.routes = nil
+}
+
+// Handler is an echo handler which responds with the unchecked routes report.
+func (a *AccessAudit) Handler(c echo.Context) error {
+	return c.JSON(http.StatusOK, echo.Map{
+		"unchecked_routes": a.UncheckedRoutes(),
+	})
+}
+
+// NewAccessAudit returns a new empty access audit.
+func NewAccessAudit() *AccessAudit {
+	return &AccessAudit{}
+}
+
+func (c Config) auditingAccess() bool {
+	return c.AccessAudit != nil || c.StrictAccessCheck != StrictAccessCheckDisabled
+}
+
+// strictAccessCheck returns the strict access check mode in effect.
+// [StrictAccessCheckError] is downgraded to [StrictAccessCheckLog] outside of development.
+func (c Config) strictAccessCheck() StrictAccessCheckMode {
+	if c.StrictAccessCheck == StrictAccessCheckError && !c.Development {
+		return StrictAccessCheckLog
+	}
+
+	return c.StrictAccessCheck
+}
+
+// auditAccess executes the next handler recording whether an access check was executed.
+func (c Config) auditAccess(ctx echo.Context, next echo.HandlerFunc) error {
+	reqCtx := iamruntime.SetContextAccessTracking(ctx.Request().Context())
+
+	ctx.SetRequest(ctx.Request().WithContext(reqCtx))
+
+	mode := c.strictAccessCheck()
+
+	var buffer *responseBuffer
+
+	if mode == StrictAccessCheckError {
+		buffer = bufferResponse(ctx.Response())
+	}
+
+	err := next(ctx)
+
+	status := ctx.Response().Status
+	if buffer != nil {
+		status = buffer.Status()
+	}
+
+	if err != nil || status < http.StatusOK || status >= http.StatusMultipleChoices {
+		return flushResponse(buffer, err)
+	}
+
+	checked := iamruntime.ContextAccessChecked(reqCtx)
+
+	if c.AccessAudit != nil {
+		c.AccessAudit.record(ctx.Request().Method, ctx.Path(), checked)
+	}
+
+	if checked {
+		return flushResponse(buffer, nil)
+	}
+
+	switch mode {
+	case StrictAccessCheckLog:
+		ctx.Logger().Errorf("%s: %s %s", iamruntime.ErrAccessNotChecked, ctx.Request().Method, ctx.Path())
+	case StrictAccessCheckError:
+		buffer.discard()
+
+		return echo.ErrInternalServerError.WithInternal(fmt.Errorf("%w: %s %s", iamruntime.ErrAccessNotChecked, ctx.Request().Method, ctx.Path()))
+	}
+
+	return flushResponse(buffer, nil)
+}
+
+// flushResponse flushes the buffer if one is provided, returning the provided error or any error flushing.
+func flushResponse(buffer *responseBuffer, err error) error {
+	if buffer == nil {
+		return err
+	}
+
+	if ferr := buffer.flush(); ferr != nil && err == nil {
+		return ferr
+	}
+
+	return err
+}
@@ -0,0 +1,191 @@
+package iamruntimemiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/internal/testauth"
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestAccessAudit(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	testCases := []struct {
+		name            string
+		mode            StrictAccessCheckMode
+		development     bool
+		path            string
+		expectStatus    int
+		expectBody      string
+		expectUnchecked []RouteAccessReport
+	}{
+		{
+			"checked",
+			StrictAccessCheckDisabled,
+			false,
+			"/checked/testten-abc123",
+			http.StatusOK,
+			"checked",
+			[]RouteAccessReport{},
+		},
+		{
+			"unchecked",
+			StrictAccessCheckDisabled,
+			false,
+			"/unchecked/testten-abc123",
+			http.StatusOK,
+			"unchecked",
+			[]RouteAccessReport{
+				{Method: http.MethodGet, Path: "/unchecked/:id", Unchecked: 1},
+			},
+		},
+		{
+			"unchecked failure",
+			StrictAccessCheckError,
+			true,
+			"/failure",
+			http.StatusNotFound,
+			"{\"message\":\"Not Found\"}\n",
+			[]RouteAccessReport{},
+		},
+		{
+			"unchecked log",
+			StrictAccessCheckLog,
+			false,
+			"/unchecked/testten-abc123",
+			http.StatusOK,
+			"unchecked",
+			[]RouteAccessReport{
+				{Method: http.MethodGet, Path: "/unchecked/:id", Unchecked: 1},
+			},
+		},
+		{
+			"unchecked strict without development",
+			StrictAccessCheckError,
+			false,
+			"/unchecked/testten-abc123",
+			http.StatusOK,
+			"unchecked",
+			[]RouteAccessReport{
+				{Method: http.MethodGet, Path: "/unchecked/:id", Unchecked: 1},
+			},
+		},
+		{
+			"checked strict",
+			StrictAccessCheckError,
+			true,
+			"/checked/testten-abc123",
+			http.StatusOK,
+			"checked",
+			[]RouteAccessReport{},
+		},
+		{
+			"unchecked strict",
+			StrictAccessCheckError,
+			true,
+			"/unchecked/testten-abc123",
+			http.StatusInternalServerError,
+			"{\"message\":\"Internal Server Error\"}\n",
+			[]RouteAccessReport{
+				{Method: http.MethodGet, Path: "/unchecked/:id", Unchecked: 1},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			runtime.Mock.On("ValidateCredential", "some subject").Return(&authentication.ValidateCredentialResponse{
+				Result: authentication.ValidateCredentialResponse_RESULT_VALID,
+			}, nil)
+
+			runtime.Mock.On("CheckAccess", map[string][]string{"testten-abc123": {"action_one"}}).Return(authorization.CheckAccessResponse_RESULT_ALLOWED, nil).Maybe()
+
+			audit := NewAccessAudit()
+
+			config := NewConfig().WithRuntime(runtime).WithAccessAudit(audit).WithStrictAccessCheck(tc.mode).WithDevelopment(tc.development)
+
+			middleware, err := config.ToMiddleware()
+			require.NoError(t, err, "unexpected error building middleware")
+
+			engine := echo.New()
+
+			engine.Use(middleware)
+
+			engine.GET("/checked/:id", func(c echo.Context) error {
+				if err := CheckAccessTo(c, c.Param("id"), "action_one"); err != nil {
+					return err
+				}
+
+				return c.String(http.StatusOK, "checked")
+			})
+
+			engine.GET("/unchecked/:id", func(c echo.Context) error {
+				return c.String(http.StatusOK, "unchecked")
+			})
+
+			engine.GET("/failure", func(_ echo.Context) error {
+				return echo.ErrNotFound
+			})
+
+			ctx := context.Background()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+
+			req.Header.Add("Authorization", "Bearer "+authsrv.TSignSubject(t, "some subject"))
+
+			resp := httptest.NewRecorder()
+
+			engine.ServeHTTP(resp, req)
+
+			runtime.Mock.AssertExpectations(t)
+
+			assert.Equal(t, tc.expectStatus, resp.Code, "unexpected status code returned")
+			assert.Equal(t, tc.expectBody, resp.Body.String(), "unexpected body returned")
+
+			unchecked := audit.UncheckedRoutes()
+
+			for i := range unchecked {
+				assert.False(t, unchecked[i].LastUnchecked.IsZero(), "expected last unchecked time to be set")
+
+				unchecked[i].LastUnchecked = tc.expectUnchecked[i].LastUnchecked
+			}
+
+			assert.Equal(t, tc.expectUnchecked, unchecked, "unexpected unchecked routes")
+		})
+	}
+}
+
+func ExampleAccessAudit() {
+	audit := NewAccessAudit()
+
+	middleware, _ := NewConfig().WithAccessAudit(audit).ToMiddleware()
+
+	engine := echo.New()
+
+	engine.GET("/debug/unchecked-routes", audit.Handler)
+
+	api := engine.Group("/api", middleware)
+
+	api.GET("/resources/:resource_id", func(c echo.Context) error {
+		if err := CheckAccessTo(c, c.Param("resource_id"), "resource_get"); err != nil {
+			return err
+		}
+
+		return c.String(http.StatusOK, "user has access to resource")
+	})
+
+	_ = http.ListenAndServe(":8080", engine)
+}
@@ -14,32 +14,119 @@ import (
 	"github.com/metal-toolbox/iam-runtime-contrib/internal"
 )
 
-func setAuthenticationContext(c echo.Context) error {
-	bearer, err := internal.GetBearerToken(c.Request())
+// setAuthenticationContext validates the request credential with the runtime.
+// The credential, subject and subject claims are only set on the request context once the runtime has validated the credential.
+func (c Config) setAuthenticationContext(ctx echo.Context) error {
+	bearer, err := c.extractToken(ctx)
 	if err != nil {
-		return echo.ErrUnauthorized.WithInternal(fmt.Errorf("%w: %s", iamruntime.AuthError, err))
+		return err
 	}
 
-	ctx := c.Request().Context()
+	if bearer == "" {
+		if c.OptionalAuthentication {
+			return nil
+		}
 
-	token, _, err := jwt.NewParser().ParseUnverified(bearer, jwt.MapClaims{})
-	if err != nil {
-		return echo.ErrUnauthorized.WithInternal(fmt.Errorf("%w: failed to parse jwt: %w", iamruntime.AuthError, err))
+		return echo.ErrUnauthorized.WithInternal(fmt.Errorf("%w: %w", iamruntime.AuthError, internal.ErrInvalidAuthToken))
 	}
 
-	subject, err := token.Claims.GetSubject()
+	credential, err := c.parseCredential(bearer)
 	if err != nil {
-		return echo.ErrUnauthorized.WithInternal(fmt.Errorf("%w: failed to get subject from jwt: %w", iamruntime.AuthError, err))
+		return err
+	}
+
+	reqCtx := ctx.Request().Context()
+
+	if c.Prevalidator != nil {
+		if err = c.Prevalidator.Prevalidate(reqCtx, credential); err != nil {
+			return prevalidateError(err)
+		}
 	}
 
-	ctx = iamruntime.SetContextToken(ctx, token)
-	ctx = iamruntime.SetContextSubject(ctx, subject)
+	if c.RevocationChecker != nil {
+		revoked, err := c.RevocationChecker.IsRevoked(reqCtx, credential)
+		if err != nil {
+			if errors.Is(err, iamruntime.ErrClaimsInvalid) {
+				return echo.ErrUnauthorized.WithInternal(err)
+			}
 
-	c.SetRequest(c.Request().WithContext(ctx))
+			return echo.ErrInternalServerError.WithInternal(fmt.Errorf("%w: %w", iamruntime.ErrRevocationCheckFailed, err))
+		}
+
+		if revoked {
+			return echo.ErrUnauthorized.WithInternal(iamruntime.ErrCredentialRevoked)
+		}
+	}
 
-	return ValidateCredential(c, &authentication.ValidateCredentialRequest{
+	subject, err := iamruntime.ContextValidateCredentialSubject(reqCtx, &authentication.ValidateCredentialRequest{
 		Credential: bearer,
 	})
+	if err != nil {
+		return validateCredentialError(err)
+	}
+
+	subjectID, err := resolveSubject(credential, subject)
+	if err != nil {
+		return echo.ErrUnauthorized.WithInternal(err)
+	}
+
+	reqCtx = iamruntime.SetContextCredential(reqCtx, credential)
+	reqCtx = iamruntime.SetContextSubject(reqCtx, subjectID)
+
+	if claims := subject.GetClaims(); claims != nil {
+		reqCtx = iamruntime.SetContextSubjectClaims(reqCtx, claims.AsMap())
+	}
+
+	ctx.SetRequest(ctx.Request().WithContext(reqCtx))
+
+	return nil
+}
+
+// parseCredential decodes the raw credential as a jwt.
+// If OpaqueCredentials is enabled, the raw credential is returned as an opaque credential.
+func (c Config) parseCredential(raw string) (*iamruntime.Credential, error) {
+	if c.OpaqueCredentials {
+		return iamruntime.NewOpaqueCredential(raw), nil
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(raw, jwt.MapClaims{})
+	if err != nil {
+		return nil, echo.ErrUnauthorized.WithInternal(fmt.Errorf("%w: failed to parse jwt: %w", iamruntime.AuthError, err))
+	}
+
+	return iamruntime.NewTokenCredential(token), nil
+}
+
+// resolveSubject returns the subject id returned by the runtime.
+// If the runtime does not return a subject, the jwt subject claim is used.
+// An error is returned if the runtime subject does not match the jwt subject claim,
+// or if neither the runtime nor the jwt provide a subject.
+func resolveSubject(credential *iamruntime.Credential, subject *authentication.Subject) (string, error) {
+	if credential.IsOpaque() {
+		if subject.GetSubjectId() == "" {
+			return "", fmt.Errorf("%w: runtime did not return a subject for opaque credential", iamruntime.ErrSubjectMissing)
+		}
+
+		return subject.GetSubjectId(), nil
+	}
+
+	tokenSubject, err := credential.Token.Claims.GetSubject()
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to get subject from jwt: %w", iamruntime.AuthError, err)
+	}
+
+	runtimeSubject := subject.GetSubjectId()
+
+	switch {
+	case runtimeSubject == "" && tokenSubject == "":
+		return "", fmt.Errorf("%w: runtime and jwt did not provide a subject", iamruntime.ErrSubjectMissing)
+	case runtimeSubject == "":
+		return tokenSubject, nil
+	case tokenSubject != "" && runtimeSubject != tokenSubject:
+		return "", fmt.Errorf("%w: %s != %s", iamruntime.ErrSubjectMismatch, runtimeSubject, tokenSubject)
+	default:
+		return runtimeSubject, nil
+	}
 }
 
 // ValidateCredential executes an access request on the runtime in the context with the provided actions.
@@ -51,15 +138,34 @@ func ValidateCredential(c echo.Context, in *authentication.ValidateCredentialReq
 // ContextValidateCredential same as [ValidateCredential] except it works off a context.Context.
 func ContextValidateCredential(ctx context.Context, in *authentication.ValidateCredentialRequest, opts ...grpc.CallOption) error {
 	if err := iamruntime.ContextValidateCredential(ctx, in, opts...); err != nil {
-		switch {
-		case errors.Is(err, iamruntime.ErrTokenNotFound), errors.Is(err, iamruntime.ErrInvalidCredentials):
-			return echo.ErrUnauthorized.WithInternal(err)
-		case errors.Is(err, iamruntime.ErrRuntimeNotFound), errors.Is(err, iamruntime.ErrCredentialValidationRequestFailed):
-			return echo.ErrInternalServerError.WithInternal(err)
-		default:
-			return echo.ErrInternalServerError.WithInternal(fmt.Errorf("unknown error: %w", err))
-		}
+		return validateCredentialError(err)
 	}
 
 	return nil
 }
+
+// validateCredentialError converts a credential validation error to an echo error with a proper status code.
+func validateCredentialError(err error) error {
+	switch {
+	case errors.Is(err, iamruntime.ErrTokenNotFound), errors.Is(err, iamruntime.ErrInvalidCredentials):
+		return echo.ErrUnauthorized.WithInternal(err)
+	case errors.Is(err, iamruntime.ErrRuntimeNotFound), errors.Is(err, iamruntime.ErrCredentialValidationRequestFailed):
+		return echo.ErrInternalServerError.WithInternal(err)
+	default:
+		return echo.ErrInternalServerError.WithInternal(fmt.Errorf("unknown error: %w", err))
+	}
+}
+
+// RequireAuthentication returns a middleware which rejects requests which have not been authenticated.
+// This is useful for protecting subroutes when [Config.OptionalAuthentication] is enabled.
+func RequireAuthentication() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !IsAuthenticated(c) {
+				return echo.ErrUnauthorized.WithInternal(iamruntime.ErrTokenNotFound)
+			}
+
+			return next(c)
+		}
+	}
+}
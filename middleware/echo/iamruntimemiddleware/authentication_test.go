@@ -2,10 +2,12 @@ package iamruntimemiddleware
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/labstack/echo/v4"
@@ -13,6 +15,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
 	"github.com/metal-toolbox/iam-runtime-contrib/internal/testauth"
@@ -90,6 +93,406 @@ func TestValidateCredential(t *testing.T) {
 	}
 }
 
+func TestRuntimeSubject(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	claims, err := structpb.NewStruct(map[string]any{"email": "user@example.com"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		tokenSubject string
+		subject      *authentication.Subject
+		expectStatus int
+		expectBody   map[string]any
+	}{
+		{
+			"no runtime subject",
+			"some subject",
+			nil,
+			http.StatusOK,
+			map[string]any{
+				"subject": "some subject",
+				"claims":  nil,
+			},
+		},
+		{
+			"matching runtime subject",
+			"some subject",
+			&authentication.Subject{SubjectId: "some subject", Claims: claims},
+			http.StatusOK,
+			map[string]any{
+				"subject": "some subject",
+				"claims":  map[string]any{"email": "user@example.com"},
+			},
+		},
+		{
+			"mismatched runtime subject",
+			"some subject",
+			&authentication.Subject{SubjectId: "other subject"},
+			http.StatusUnauthorized,
+			map[string]any{
+				"message": "Unauthorized",
+				"error":   "code=401, message=Unauthorized, internal=iam-runtime error: auth: runtime subject does not match token subject: other subject != some subject",
+			},
+		},
+		{
+			"no subject",
+			"",
+			nil,
+			http.StatusUnauthorized,
+			map[string]any{
+				"message": "Unauthorized",
+				"error":   "code=401, message=Unauthorized, internal=iam-runtime error: auth: subject missing: runtime and jwt did not provide a subject",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			runtime.Mock.On("ValidateCredential", tc.tokenSubject).Return(&authentication.ValidateCredentialResponse{
+				Result:  authentication.ValidateCredentialResponse_RESULT_VALID,
+				Subject: tc.subject,
+			}, nil)
+
+			middleware, err := NewConfig().WithRuntime(runtime).ToMiddleware()
+			require.NoError(t, err, "unexpected error building middleware")
+
+			engine := echo.New()
+
+			engine.Debug = true
+
+			engine.Use(middleware)
+
+			engine.GET("/test", func(c echo.Context) error {
+				return c.JSON(http.StatusOK, echo.Map{
+					"subject": ContextSubject(c),
+					"claims":  ContextSubjectClaims(c),
+				})
+			})
+
+			ctx := context.Background()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+
+			req.Header.Add("Authorization", "Bearer "+authsrv.TSignSubject(t, tc.tokenSubject))
+
+			resp := httptest.NewRecorder()
+
+			engine.ServeHTTP(resp, req)
+
+			runtime.Mock.AssertExpectations(t)
+
+			assert.Equal(t, tc.expectStatus, resp.Code, "unexpected status code returned")
+
+			var body map[string]any
+
+			err = json.Unmarshal(resp.Body.Bytes(), &body)
+			require.NoError(t, err, "unexpected error decoding body")
+
+			assert.Equal(t, tc.expectBody, body, "unexpected body returned")
+		})
+	}
+}
+
+type revocationCheckerFunc func(ctx context.Context, credential *iamruntime.Credential) (bool, error)
+
+func (f revocationCheckerFunc) IsRevoked(ctx context.Context, credential *iamruntime.Credential) (bool, error) {
+	return f(ctx, credential)
+}
+
+func TestRevocationChecker(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	testCases := []struct {
+		name          string
+		revoked       bool
+		revokedError  error
+		expectRuntime bool
+		expectStatus  int
+		expectError   error
+	}{
+		{
+			"not revoked",
+			false,
+			nil,
+			true,
+			http.StatusOK,
+			nil,
+		},
+		{
+			"revoked",
+			true,
+			nil,
+			false,
+			http.StatusUnauthorized,
+			iamruntime.ErrCredentialRevoked,
+		},
+		{
+			"check failed",
+			false,
+			grpc.ErrServerStopped,
+			false,
+			http.StatusInternalServerError,
+			iamruntime.ErrRevocationCheckFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			if tc.expectRuntime {
+				runtime.Mock.On("ValidateCredential", "some subject").Return(&authentication.ValidateCredentialResponse{
+					Result: authentication.ValidateCredentialResponse_RESULT_VALID,
+				}, nil)
+			}
+
+			checker := revocationCheckerFunc(func(_ context.Context, credential *iamruntime.Credential) (bool, error) {
+				assert.False(t, credential.IsOpaque(), "expected token credential")
+
+				return tc.revoked, tc.revokedError
+			})
+
+			middleware, err := NewConfig().WithRuntime(runtime).WithRevocationChecker(checker).ToMiddleware()
+			require.NoError(t, err, "unexpected error building middleware")
+
+			engine := echo.New()
+
+			var returnedErr error
+
+			engine.HTTPErrorHandler = func(err error, c echo.Context) {
+				returnedErr = err
+
+				engine.DefaultHTTPErrorHandler(err, c)
+			}
+
+			engine.Use(middleware)
+
+			engine.GET("/test", func(c echo.Context) error {
+				return c.String(http.StatusOK, ContextSubject(c))
+			})
+
+			ctx := context.Background()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+
+			req.Header.Add("Authorization", "Bearer "+authsrv.TSignSubject(t, "some subject"))
+
+			resp := httptest.NewRecorder()
+
+			engine.ServeHTTP(resp, req)
+
+			runtime.Mock.AssertExpectations(t)
+
+			assert.Equal(t, tc.expectStatus, resp.Code, "unexpected status code returned")
+
+			if tc.expectError != nil {
+				assert.ErrorIs(t, returnedErr, tc.expectError, "unexpected error returned")
+			}
+		})
+	}
+}
+
+func TestOptionalAuthentication(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	testCases := []struct {
+		name                   string
+		path                   string
+		authorization          string
+		authenticationResponse authentication.ValidateCredentialResponse_Result
+		expectStatus           int
+		expectBody             string
+	}{
+		{
+			"anonymous",
+			"/public",
+			"",
+			0,
+			http.StatusOK,
+			"anonymous",
+		},
+		{
+			"authenticated",
+			"/public",
+			"Bearer " + authsrv.TSignSubject(t, "some subject"),
+			authentication.ValidateCredentialResponse_RESULT_VALID,
+			http.StatusOK,
+			"some subject",
+		},
+		{
+			"invalid credentials",
+			"/public",
+			"Bearer " + authsrv.TSignSubject(t, "some subject"),
+			authentication.ValidateCredentialResponse_RESULT_INVALID,
+			http.StatusUnauthorized,
+			"{\"message\":\"Unauthorized\"}\n",
+		},
+		{
+			"malformed scheme",
+			"/public",
+			"Basic dXNlcjpwYXNz",
+			0,
+			http.StatusUnauthorized,
+			"{\"message\":\"Unauthorized\"}\n",
+		},
+		{
+			"missing bearer token",
+			"/public",
+			"Bearer",
+			0,
+			http.StatusUnauthorized,
+			"{\"message\":\"Unauthorized\"}\n",
+		},
+		{
+			"required anonymous",
+			"/private",
+			"",
+			0,
+			http.StatusUnauthorized,
+			"{\"message\":\"Unauthorized\"}\n",
+		},
+		{
+			"required authenticated",
+			"/private",
+			"Bearer " + authsrv.TSignSubject(t, "some subject"),
+			authentication.ValidateCredentialResponse_RESULT_VALID,
+			http.StatusOK,
+			"some subject",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			// Malformed credentials are rejected before they are validated.
+			if token, ok := strings.CutPrefix(tc.authorization, "Bearer "); ok && token != "" {
+				runtime.Mock.On("ValidateCredential", "some subject").Return(&authentication.ValidateCredentialResponse{
+					Result: tc.authenticationResponse,
+				}, nil)
+			}
+
+			middleware, err := NewConfig().WithRuntime(runtime).WithOptionalAuthentication(true).ToMiddleware()
+			require.NoError(t, err, "unexpected error building middleware")
+
+			engine := echo.New()
+
+			engine.Use(middleware)
+
+			handler := func(c echo.Context) error {
+				if !IsAuthenticated(c) {
+					return c.String(http.StatusOK, "anonymous")
+				}
+
+				return c.String(http.StatusOK, ContextSubject(c))
+			}
+
+			engine.GET("/public", handler)
+			engine.GET("/private", handler, RequireAuthentication())
+
+			ctx := context.Background()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+
+			if tc.authorization != "" {
+				req.Header.Add("Authorization", tc.authorization)
+			}
+
+			resp := httptest.NewRecorder()
+
+			engine.ServeHTTP(resp, req)
+
+			runtime.Mock.AssertExpectations(t)
+
+			assert.Equal(t, tc.expectStatus, resp.Code, "unexpected status code returned")
+			assert.Equal(t, tc.expectBody, resp.Body.String(), "unexpected body returned")
+		})
+	}
+}
+
+func TestOpaqueCredentials(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		authenticationResponse *authentication.ValidateCredentialResponse
+		expectStatus           int
+		expectBody             string
+	}{
+		{
+			"valid",
+			&authentication.ValidateCredentialResponse{
+				Result:  authentication.ValidateCredentialResponse_RESULT_VALID,
+				Subject: &authentication.Subject{SubjectId: "idntusr-abc123"},
+			},
+			http.StatusOK,
+			"idntusr-abc123 some-api-key",
+		},
+		{
+			"invalid",
+			&authentication.ValidateCredentialResponse{Result: authentication.ValidateCredentialResponse_RESULT_INVALID},
+			http.StatusUnauthorized,
+			"{\"message\":\"Unauthorized\"}\n",
+		},
+		{
+			"missing subject",
+			&authentication.ValidateCredentialResponse{
+				Result:  authentication.ValidateCredentialResponse_RESULT_VALID,
+				Subject: &authentication.Subject{},
+			},
+			http.StatusUnauthorized,
+			"{\"message\":\"Unauthorized\"}\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			runtime.Mock.On("ValidateCredential", "some-api-key").Return(tc.authenticationResponse, nil)
+
+			middleware, err := NewConfig().WithRuntime(runtime).WithOpaqueCredentials(true).ToMiddleware()
+			require.NoError(t, err, "unexpected error building middleware")
+
+			engine := echo.New()
+
+			engine.Use(middleware)
+
+			engine.GET("/test", func(c echo.Context) error {
+				if ContextToken(c) != nil {
+					return echo.ErrNotAcceptable
+				}
+
+				return c.String(http.StatusOK, ContextSubject(c)+" "+ContextCredential(c).Raw)
+			})
+
+			ctx := context.Background()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+
+			req.Header.Add("Authorization", "Bearer some-api-key")
+
+			resp := httptest.NewRecorder()
+
+			engine.ServeHTTP(resp, req)
+
+			runtime.Mock.AssertExpectations(t)
+
+			assert.Equal(t, tc.expectStatus, resp.Code, "unexpected status code returned")
+			assert.Equal(t, tc.expectBody, resp.Body.String(), "unexpected body returned")
+		})
+	}
+}
+
 func ExampleValidateCredential() {
 	middleware, _ := NewConfig().ToMiddleware()
 
@@ -113,3 +516,25 @@ func ExampleValidateCredential() {
 
 	_ = http.ListenAndServe(":8080", engine)
 }
+
+func ExampleRequireAuthentication() {
+	middleware, _ := NewConfig().WithOptionalAuthentication(true).ToMiddleware()
+
+	engine := echo.New()
+
+	engine.Use(middleware)
+
+	engine.GET("/articles", func(c echo.Context) error {
+		if IsAuthenticated(c) {
+			return c.String(http.StatusOK, "articles including drafts for "+ContextSubject(c))
+		}
+
+		return c.String(http.StatusOK, "published articles")
+	})
+
+	engine.POST("/articles", func(c echo.Context) error {
+		return c.String(http.StatusCreated, "article created")
+	}, RequireAuthentication())
+
+	_ = http.ListenAndServe(":8080", engine)
+}
@@ -80,12 +80,7 @@ func CreateRelationships(c echo.Context, in *authorization.CreateRelationshipsRe
 func ContextCreateRelationships(ctx context.Context, in *authorization.CreateRelationshipsRequest, opts ...grpc.CallOption) (*authorization.CreateRelationshipsResponse, error) {
 	resp, err := iamruntime.ContextCreateRelationships(ctx, in, opts...)
 	if err != nil {
-		switch {
-		case errors.Is(err, iamruntime.ErrRuntimeNotFound), errors.Is(err, iamruntime.ErrRelationshipRequestFailed):
-			return nil, echo.ErrInternalServerError.WithInternal(err)
-		default:
-			return nil, echo.ErrInternalServerError.WithInternal(fmt.Errorf("unknown error: %w", err))
-		}
+		return nil, relationshipError(err)
 	}
 
 	return resp, nil
@@ -101,13 +96,27 @@ func DeleteRelationships(c echo.Context, in *authorization.DeleteRelationshipsRe
 func ContextDeleteRelationships(ctx context.Context, in *authorization.DeleteRelationshipsRequest, opts ...grpc.CallOption) (*authorization.DeleteRelationshipsResponse, error) {
 	resp, err := iamruntime.ContextDeleteRelationships(ctx, in, opts...)
 	if err != nil {
-		switch {
-		case errors.Is(err, iamruntime.ErrRuntimeNotFound), errors.Is(err, iamruntime.ErrRelationshipRequestFailed):
-			return nil, echo.ErrInternalServerError.WithInternal(err)
-		default:
-			return nil, echo.ErrInternalServerError.WithInternal(fmt.Errorf("unknown error: %w", err))
-		}
+		return nil, relationshipError(err)
 	}
 
 	return resp, nil
 }
+
+// relationshipError converts a relationship error to an echo error with a proper status code.
+func relationshipError(err error) error {
+	switch {
+	case errors.Is(err, iamruntime.ErrRelationshipInvalid):
+		return echo.ErrBadRequest.WithInternal(err)
+	case errors.Is(err, iamruntime.ErrRelationshipConflict):
+		return echo.ErrConflict.WithInternal(err)
+	case errors.Is(err, iamruntime.ErrRelationshipNotFound):
+		return echo.ErrNotFound.WithInternal(err)
+	case errors.Is(err, iamruntime.ErrRelationshipPermissionDenied):
+		return echo.ErrForbidden.WithInternal(err)
+	case errors.Is(err, iamruntime.ErrRuntimeNotFound), errors.Is(err, iamruntime.ErrRelationshipRequestFailed),
+		errors.Is(err, iamruntime.ErrRelationshipIDInvalid), errors.Is(err, iamruntime.ErrRelationInvalid):
+		return echo.ErrInternalServerError.WithInternal(err)
+	default:
+		return echo.ErrInternalServerError.WithInternal(fmt.Errorf("unknown error: %w", err))
+	}
+}
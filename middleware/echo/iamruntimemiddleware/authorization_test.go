@@ -13,6 +13,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
 	"github.com/metal-toolbox/iam-runtime-contrib/internal/testauth"
@@ -306,6 +308,74 @@ func TestCreateRelationships(t *testing.T) {
 			},
 			echo.ErrInternalServerError,
 		},
+		{
+			"invalid",
+			&authorization.CreateRelationshipsRequest{
+				ResourceId: "testten-abc123",
+				Relationships: []*authorization.Relationship{
+					{
+						Relation:  "parent",
+						SubjectId: "testten-root123",
+					},
+				},
+			},
+			status.Error(codes.InvalidArgument, "invalid"),
+			map[string][]string{
+				"parent": {"testten-root123"},
+			},
+			echo.ErrBadRequest,
+		},
+		{
+			"conflict",
+			&authorization.CreateRelationshipsRequest{
+				ResourceId: "testten-abc123",
+				Relationships: []*authorization.Relationship{
+					{
+						Relation:  "parent",
+						SubjectId: "testten-root123",
+					},
+				},
+			},
+			status.Error(codes.AlreadyExists, "conflict"),
+			map[string][]string{
+				"parent": {"testten-root123"},
+			},
+			echo.ErrConflict,
+		},
+		{
+			"not found",
+			&authorization.CreateRelationshipsRequest{
+				ResourceId: "testten-abc123",
+				Relationships: []*authorization.Relationship{
+					{
+						Relation:  "parent",
+						SubjectId: "testten-root123",
+					},
+				},
+			},
+			status.Error(codes.NotFound, "not found"),
+			map[string][]string{
+				"parent": {"testten-root123"},
+			},
+			echo.ErrNotFound,
+		},
+		{
+			"permission denied",
+			&authorization.CreateRelationshipsRequest{
+				ResourceId: "testten-abc123",
+				Relationships: []*authorization.Relationship{
+					{
+						Relation:  "parent",
+						SubjectId: "testten-root123",
+					},
+				},
+			},
+			status.Error(codes.PermissionDenied, "permission denied"),
+			map[string][]string{
+				"parent": {"testten-root123"},
+			},
+			echo.ErrForbidden,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -389,6 +459,74 @@ func TestDeleteRelationships(t *testing.T) {
 			},
 			echo.ErrInternalServerError,
 		},
+		{
+			"invalid",
+			&authorization.DeleteRelationshipsRequest{
+				ResourceId: "testten-abc123",
+				Relationships: []*authorization.Relationship{
+					{
+						Relation:  "parent",
+						SubjectId: "testten-root123",
+					},
+				},
+			},
+			status.Error(codes.InvalidArgument, "invalid"),
+			map[string][]string{
+				"parent": {"testten-root123"},
+			},
+			echo.ErrBadRequest,
+		},
+		{
+			"conflict",
+			&authorization.DeleteRelationshipsRequest{
+				ResourceId: "testten-abc123",
+				Relationships: []*authorization.Relationship{
+					{
+						Relation:  "parent",
+						SubjectId: "testten-root123",
+					},
+				},
+			},
+			status.Error(codes.AlreadyExists, "conflict"),
+			map[string][]string{
+				"parent": {"testten-root123"},
+			},
+			echo.ErrConflict,
+		},
+		{
+			"not found",
+			&authorization.DeleteRelationshipsRequest{
+				ResourceId: "testten-abc123",
+				Relationships: []*authorization.Relationship{
+					{
+						Relation:  "parent",
+						SubjectId: "testten-root123",
+					},
+				},
+			},
+			status.Error(codes.NotFound, "not found"),
+			map[string][]string{
+				"parent": {"testten-root123"},
+			},
+			echo.ErrNotFound,
+		},
+		{
+			"permission denied",
+			&authorization.DeleteRelationshipsRequest{
+				ResourceId: "testten-abc123",
+				Relationships: []*authorization.Relationship{
+					{
+						Relation:  "parent",
+						SubjectId: "testten-root123",
+					},
+				},
+			},
+			status.Error(codes.PermissionDenied, "permission denied"),
+			map[string][]string{
+				"parent": {"testten-root123"},
+			},
+			echo.ErrForbidden,
+		},
 	}
 
 	for _, tc := range testCases {
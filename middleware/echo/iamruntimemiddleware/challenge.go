@@ -0,0 +1,109 @@
+package iamruntimemiddleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+	"github.com/metal-toolbox/iam-runtime-contrib/internal"
+)
+
+// Bearer token error codes defined by RFC 6750 section 3.1.
+const (
+	bearerErrorInvalidRequest    = "invalid_request"
+	bearerErrorInvalidToken      = "invalid_token"
+	bearerErrorInsufficientScope = "insufficient_scope"
+)
+
+// bearerError maps an iamruntime error to a bearer token error code and description.
+type bearerError struct {
+	err         error
+	code        string
+	description string
+}
+
+// bearerErrors are matched in order, so more specific errors must come before the errors they wrap.
+var bearerErrors = []bearerError{
+	{iamruntime.ErrMultipleTokens, bearerErrorInvalidRequest, "multiple tokens provided"},
+	{iamruntime.ErrCredentialRevoked, bearerErrorInvalidToken, "the access token has been revoked"},
+	{iamruntime.ErrTokenPrevalidationFailed, bearerErrorInvalidToken, "the access token is expired, malformed or not intended for this resource"},
+	{iamruntime.ErrInvalidCredentials, bearerErrorInvalidToken, "the access token is invalid"},
+	{iamruntime.ErrSubjectMismatch, bearerErrorInvalidToken, "the access token subject is invalid"},
+	{iamruntime.ErrClaimsInvalid, bearerErrorInvalidToken, "the access token claims are invalid"},
+	{iamruntime.ErrInsufficientScope, bearerErrorInsufficientScope, "the access token does not have the required scope"},
+	{iamruntime.ErrAudienceNotAllowed, bearerErrorInsufficientScope, "the access token audience is not allowed"},
+	{iamruntime.ErrIssuerNotAllowed, bearerErrorInsufficientScope, "the access token issuer is not allowed"},
+	{iamruntime.ErrClaimNotAllowed, bearerErrorInsufficientScope, "the access token claims do not grant access"},
+	{iamruntime.ErrAccessDenied, bearerErrorInsufficientScope, "the access token does not grant access to the requested resource"},
+	{iamruntime.ErrTokenNotFound, bearerErrorInvalidRequest, "the access token is missing"},
+	{iamruntime.AuthError, bearerErrorInvalidToken, "the access token is malformed"},
+}
+
+// setAuthenticateHeader sets the WWW-Authenticate header on the response if the error is
+// an authentication or authorization error, as defined by RFC 6750.
+func (c Config) setAuthenticateHeader(ctx echo.Context, err error) {
+	if ctx.Response().Committed {
+		return
+	}
+
+	if challenge, ok := c.challenge(err); ok {
+		ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+	}
+}
+
+// challenge builds the Bearer challenge for the provided error.
+// False is returned if the error is not an iamruntime error resulting in a bad request, unauthorized or forbidden response.
+func (c Config) challenge(err error) (string, bool) {
+	var httpErr *echo.HTTPError
+
+	if !errors.As(err, &httpErr) || !errors.Is(err, iamruntime.Error) {
+		return "", false
+	}
+
+	params := make([]string, 0, 3)
+
+	if c.Realm != "" {
+		params = append(params, bearerParam("realm", c.Realm))
+	}
+
+	// Requests which did not provide a credential are challenged without an error code.
+	if httpErr.Code == http.StatusUnauthorized && (errors.Is(err, internal.ErrInvalidAuthToken) || errors.Is(err, iamruntime.ErrTokenNotFound)) {
+		return bearerChallenge(params), true
+	}
+
+	switch httpErr.Code {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+	default:
+		return "", false
+	}
+
+	for _, bearerErr := range bearerErrors {
+		if errors.Is(err, bearerErr.err) {
+			params = append(params,
+				bearerParam("error", bearerErr.code),
+				bearerParam("error_description", bearerErr.description),
+			)
+
+			return bearerChallenge(params), true
+		}
+	}
+
+	return "", false
+}
+
+func bearerChallenge(params []string) string {
+	if len(params) == 0 {
+		return "Bearer"
+	}
+
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+var bearerParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func bearerParam(name, value string) string {
+	return name + `="` + bearerParamEscaper.Replace(value) + `"`
+}
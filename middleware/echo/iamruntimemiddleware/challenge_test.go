@@ -0,0 +1,169 @@
+package iamruntimemiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestAuthenticateChallenge(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"sub":   "some subject",
+		"scope": "lb:read",
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name            string
+		realm           string
+		headers         []string
+		validate        *authentication.ValidateCredentialResponse
+		access          *authorization.CheckAccessResponse
+		middleware      []echo.MiddlewareFunc
+		expectStatus    int
+		expectChallenge string
+	}{
+		{
+			"missing token",
+			"example",
+			nil,
+			nil,
+			nil,
+			nil,
+			http.StatusUnauthorized,
+			`Bearer realm="example"`,
+		},
+		{
+			"missing token without realm",
+			"",
+			nil,
+			nil,
+			nil,
+			nil,
+			http.StatusUnauthorized,
+			`Bearer`,
+		},
+		{
+			"malformed token",
+			"example",
+			[]string{"Bearer not-a-jwt"},
+			nil,
+			nil,
+			nil,
+			http.StatusUnauthorized,
+			`Bearer realm="example", error="invalid_token", error_description="the access token is malformed"`,
+		},
+		{
+			"multiple tokens",
+			"example",
+			[]string{"Bearer " + token, "Bearer " + token},
+			nil,
+			nil,
+			nil,
+			http.StatusBadRequest,
+			`Bearer realm="example", error="invalid_request", error_description="multiple tokens provided"`,
+		},
+		{
+			"invalid token",
+			"example",
+			[]string{"Bearer " + token},
+			&authentication.ValidateCredentialResponse{Result: authentication.ValidateCredentialResponse_RESULT_INVALID},
+			nil,
+			nil,
+			http.StatusUnauthorized,
+			`Bearer realm="example", error="invalid_token", error_description="the access token is invalid"`,
+		},
+		{
+			"access denied",
+			"example",
+			[]string{"Bearer " + token},
+			&authentication.ValidateCredentialResponse{Result: authentication.ValidateCredentialResponse_RESULT_VALID},
+			&authorization.CheckAccessResponse{Result: authorization.CheckAccessResponse_RESULT_DENIED},
+			nil,
+			http.StatusForbidden,
+			`Bearer realm="example", error="insufficient_scope", error_description="the access token does not grant access to the requested resource"`,
+		},
+		{
+			"insufficient scope",
+			`quoted "realm"`,
+			[]string{"Bearer " + token},
+			&authentication.ValidateCredentialResponse{Result: authentication.ValidateCredentialResponse_RESULT_VALID},
+			nil,
+			[]echo.MiddlewareFunc{RequireScopes(iamruntime.MatchAll, "lb:write")},
+			http.StatusForbidden,
+			`Bearer realm="quoted \"realm\"", error="insufficient_scope", error_description="the access token does not have the required scope"`,
+		},
+		{
+			"permitted",
+			"example",
+			[]string{"Bearer " + token},
+			&authentication.ValidateCredentialResponse{Result: authentication.ValidateCredentialResponse_RESULT_VALID},
+			&authorization.CheckAccessResponse{Result: authorization.CheckAccessResponse_RESULT_ALLOWED},
+			nil,
+			http.StatusOK,
+			"",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			if tc.validate != nil {
+				runtime.Mock.On("ValidateCredential", "some subject").Return(tc.validate, nil)
+			}
+
+			if tc.access != nil {
+				runtime.Mock.On("CheckAccess", map[string][]string{"testten-abc123": {"action_one"}}).Return(tc.access.Result, nil)
+			}
+
+			middleware, err := NewConfig().WithRuntime(runtime).WithRejectMultipleTokens(true).WithRealm(tc.realm).ToMiddleware()
+			require.NoError(t, err, "unexpected error building middleware")
+
+			engine := echo.New()
+
+			engine.Use(middleware)
+
+			engine.GET("/test", func(c echo.Context) error {
+				if tc.access == nil {
+					return c.String(http.StatusOK, "success")
+				}
+
+				if err := CheckAccessTo(c, "testten-abc123", "action_one"); err != nil {
+					return err
+				}
+
+				return c.String(http.StatusOK, "success")
+			}, tc.middleware...)
+
+			ctx := context.Background()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+
+			for _, header := range tc.headers {
+				req.Header.Add("Authorization", header)
+			}
+
+			resp := httptest.NewRecorder()
+
+			engine.ServeHTTP(resp, req)
+
+			runtime.Mock.AssertExpectations(t)
+
+			assert.Equal(t, tc.expectStatus, resp.Code, "unexpected status code returned")
+			assert.Equal(t, tc.expectChallenge, resp.Header().Get(echo.HeaderWWWAuthenticate), "unexpected challenge returned")
+		})
+	}
+}
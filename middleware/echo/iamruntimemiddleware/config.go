@@ -4,6 +4,8 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
 	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
 )
 
 const defaultRuntimePath = "/tmp/runtime.sock"
@@ -29,6 +31,61 @@ type Config struct {
 	// If no runtime is provided, a new runtime client is created using the Socket path.
 	Runtime Runtime
 
+	// TokenExtractor defines how credentials are extracted from requests.
+	// Default is [BearerTokenExtractor].
+	TokenExtractor TokenExtractor
+
+	// RejectMultipleTokens rejects requests where the TokenExtractor finds more than one token.
+	// Default is false, the first token found is used.
+	RejectMultipleTokens bool
+
+	// OpaqueCredentials passes credentials to the runtime without decoding them as a jwt.
+	// The subject is set from the runtime's validation response instead of the token claims.
+	// Use [ContextCredential] to retrieve the credential, as [ContextToken] returns nil for opaque credentials.
+	// Default is false, credentials must be a jwt.
+	OpaqueCredentials bool
+
+	// Prevalidator validates credentials locally before they are validated by the runtime.
+	// Credentials rejected by the prevalidator are not sent to the runtime.
+	// See [NewJWKSPrevalidator] for validating jwt credentials against a JSON Web Key Set.
+	// Default is nil, credentials are only validated by the runtime.
+	Prevalidator Prevalidator
+
+	// RevocationChecker rejects revoked credentials before they are validated by the runtime.
+	// See the iamruntimerevocation package for denylist implementations.
+	// Default is nil, revocation is not checked.
+	RevocationChecker iamruntime.RevocationChecker
+
+	// OptionalAuthentication allows requests without a credential to continue unauthenticated.
+	// Provided credentials are still validated and rejected if invalid.
+	// Use [IsAuthenticated] to determine if a request is authenticated
+	// and [RequireAuthentication] to require authentication on specific routes.
+	// Default is false, requests without a credential are rejected.
+	OptionalAuthentication bool
+
+	// AccessAudit records whether successful requests executed an access check.
+	// Default is nil, no recording is done.
+	AccessAudit *AccessAudit
+
+	// StrictAccessCheck defines how requests which complete successfully without an access check are handled.
+	// Default is [StrictAccessCheckDisabled].
+	StrictAccessCheck StrictAccessCheckMode
+
+	// Development enables behavior intended only for development environments,
+	// such as failing unchecked requests with [StrictAccessCheckError]
+	// and including error details in [ProblemErrorHandler] responses.
+	// Default is false.
+	Development bool
+
+	// Realm defines the realm included in WWW-Authenticate challenges.
+	// Default is empty, no realm is included.
+	Realm string
+
+	// ErrorHandler handles iamruntime errors returned by the middleware and by handlers.
+	// See [ProblemErrorHandler] for rendering RFC 7807 problem details.
+	// Default is nil, errors are returned to echo's HTTPErrorHandler.
+	ErrorHandler ErrorHandler
+
 	runtime Runtime
 }
 
@@ -53,6 +110,83 @@ func (c Config) WithRuntime(value Runtime) Config {
 	return c
 }
 
+// WithTokenExtractor returns a new [Config] with the provided token extractor set.
+func (c Config) WithTokenExtractor(value TokenExtractor) Config {
+	c.TokenExtractor = value
+
+	return c
+}
+
+// WithRejectMultipleTokens returns a new [Config] with the provided reject multiple tokens value set.
+func (c Config) WithRejectMultipleTokens(value bool) Config {
+	c.RejectMultipleTokens = value
+
+	return c
+}
+
+// WithOpaqueCredentials returns a new [Config] with the provided opaque credentials value set.
+func (c Config) WithOpaqueCredentials(value bool) Config {
+	c.OpaqueCredentials = value
+
+	return c
+}
+
+// WithPrevalidator returns a new [Config] with the provided prevalidator set.
+func (c Config) WithPrevalidator(value Prevalidator) Config {
+	c.Prevalidator = value
+
+	return c
+}
+
+// WithRevocationChecker returns a new [Config] with the provided revocation checker set.
+func (c Config) WithRevocationChecker(value iamruntime.RevocationChecker) Config {
+	c.RevocationChecker = value
+
+	return c
+}
+
+// WithOptionalAuthentication returns a new [Config] with the provided optional authentication value set.
+func (c Config) WithOptionalAuthentication(value bool) Config {
+	c.OptionalAuthentication = value
+
+	return c
+}
+
+// WithAccessAudit returns a new [Config] with the provided access audit set.
+func (c Config) WithAccessAudit(value *AccessAudit) Config {
+	c.AccessAudit = value
+
+	return c
+}
+
+// WithStrictAccessCheck returns a new [Config] with the provided strict access check mode set.
+func (c Config) WithStrictAccessCheck(value StrictAccessCheckMode) Config {
+	c.StrictAccessCheck = value
+
+	return c
+}
+
+// WithDevelopment returns a new [Config] with the provided development value set.
+func (c Config) WithDevelopment(value bool) Config {
+	c.Development = value
+
+	return c
+}
+
+// WithRealm returns a new [Config] with the provided realm set.
+func (c Config) WithRealm(value string) Config {
+	c.Realm = value
+
+	return c
+}
+
+// WithErrorHandler returns a new [Config] with the provided error handler set.
+func (c Config) WithErrorHandler(value ErrorHandler) Config {
+	c.ErrorHandler = value
+
+	return c
+}
+
 // NewConfig returns a new empty config.
 func NewConfig() Config {
 	return Config{}
@@ -20,13 +20,21 @@ func ContextRuntime(c echo.Context) Runtime {
 }
 
 // ContextToken retrieves the decoded jwt token from the provided echo context.
-// If the token is not found in the provided context, nil is returned.
+// If the token is not found in the provided context or the credential is opaque, nil is returned.
 //
 // Use ContextToken() from iamruntime if a stdlib context is being used.
 func ContextToken(c echo.Context) *jwt.Token {
 	return iamruntime.ContextToken(c.Request().Context())
 }
 
+// ContextCredential retrieves the credential from the provided echo context.
+// If the credential is not found in the provided context, nil is returned.
+//
+// Use ContextCredential() from iamruntime if a stdlib context is being used.
+func ContextCredential(c echo.Context) *iamruntime.Credential {
+	return iamruntime.ContextCredential(c.Request().Context())
+}
+
 // ContextSubject retrieves the subject from the provided echo context.
 // If the subject is not found in the provided context, an empty string is returned.
 //
@@ -34,3 +42,24 @@ func ContextToken(c echo.Context) *jwt.Token {
 func ContextSubject(c echo.Context) string {
 	return iamruntime.ContextSubject(c.Request().Context())
 }
+
+// ContextSubjectClaims retrieves the subject claims returned by the runtime from the provided echo context.
+// If the runtime did not return any claims, nil is returned.
+//
+// Use ContextSubjectClaims() from iamruntime if a stdlib context is being used.
+func ContextSubjectClaims(c echo.Context) map[string]any {
+	return iamruntime.ContextSubjectClaims(c.Request().Context())
+}
+
+// ContextClaims decodes the claims of the credential in the provided echo context into T.
+//
+// Use ContextClaims() from iamruntime if a stdlib context is being used.
+func ContextClaims[T any](c echo.Context) (T, error) {
+	return iamruntime.ContextClaims[T](c.Request().Context())
+}
+
+// IsAuthenticated returns true if the request provided a credential which was validated by the middleware.
+// Requests are only unauthenticated when [Config.OptionalAuthentication] is enabled and no credential was provided.
+func IsAuthenticated(c echo.Context) bool {
+	return ContextCredential(c) != nil
+}
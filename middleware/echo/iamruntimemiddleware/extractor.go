@@ -0,0 +1,111 @@
+package iamruntimemiddleware
+
+import (
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+	"github.com/metal-toolbox/iam-runtime-contrib/internal"
+)
+
+// TokenExtractor extracts credentials from a request.
+type TokenExtractor interface {
+	// ExtractTokens returns all credentials found in the request.
+	// If no credentials are found, an empty slice is returned.
+	ExtractTokens(c echo.Context) ([]string, error)
+}
+
+// TokenExtractorFunc adapts a function to a [TokenExtractor].
+type TokenExtractorFunc func(c echo.Context) ([]string, error)
+
+// ExtractTokens calls f(c).
+func (f TokenExtractorFunc) ExtractTokens(c echo.Context) ([]string, error) {
+	return f(c)
+}
+
+// BearerTokenExtractor returns a [TokenExtractor] which extracts Bearer tokens from the Authorization header.
+// This is the default extractor.
+func BearerTokenExtractor() TokenExtractor {
+	return HeaderTokenExtractor(echo.HeaderAuthorization, "Bearer ")
+}
+
+// HeaderTokenExtractor returns a [TokenExtractor] which extracts tokens from the provided header.
+// If a prefix is provided, the prefix is removed from the header values.
+// The prefix is matched case insensitively.
+// An error is returned if a header value does not start with the prefix or has no token after it,
+// so requests with a malformed credential are rejected rather than treated as anonymous.
+func HeaderTokenExtractor(header, prefix string) TokenExtractor {
+	return TokenExtractorFunc(func(c echo.Context) ([]string, error) {
+		return internal.GetHeaderTokens(c.Request(), header, prefix)
+	})
+}
+
+// CookieTokenExtractor returns a [TokenExtractor] which extracts tokens from the named cookie.
+func CookieTokenExtractor(name string) TokenExtractor {
+	return TokenExtractorFunc(func(c echo.Context) ([]string, error) {
+		var tokens []string
+
+		for _, cookie := range c.Request().CookiesNamed(name) {
+			if cookie.Value != "" {
+				tokens = append(tokens, cookie.Value)
+			}
+		}
+
+		return tokens, nil
+	})
+}
+
+// QueryTokenExtractor returns a [TokenExtractor] which extracts tokens from the named query parameter.
+func QueryTokenExtractor(name string) TokenExtractor {
+	return TokenExtractorFunc(func(c echo.Context) ([]string, error) {
+		var tokens []string
+
+		for _, value := range c.QueryParams()[name] {
+			if value != "" {
+				tokens = append(tokens, value)
+			}
+		}
+
+		return tokens, nil
+	})
+}
+
+// ChainTokenExtractor returns a [TokenExtractor] which returns the tokens found by all provided extractors in order.
+// The first error returned by an extractor is returned.
+func ChainTokenExtractor(extractors ...TokenExtractor) TokenExtractor {
+	return TokenExtractorFunc(func(c echo.Context) ([]string, error) {
+		var tokens []string
+
+		for _, extractor := range extractors {
+			found, err := extractor.ExtractTokens(c)
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, found...)
+		}
+
+		return tokens, nil
+	})
+}
+
+// extractToken returns the first token found by the configured extractor.
+// If no token is found, an empty string is returned.
+// If RejectMultipleTokens is enabled, an error is returned when more than one token is found.
+func (c Config) extractToken(ctx echo.Context) (string, error) {
+	tokens, err := c.TokenExtractor.ExtractTokens(ctx)
+	if err != nil {
+		return "", echo.ErrUnauthorized.WithInternal(fmt.Errorf("%w: %w", iamruntime.AuthError, err))
+	}
+
+	if len(tokens) == 0 {
+		return "", nil
+	}
+
+	if c.RejectMultipleTokens && len(tokens) > 1 {
+		return "", echo.ErrBadRequest.WithInternal(iamruntime.ErrMultipleTokens)
+	}
+
+	return tokens[0], nil
+}
@@ -0,0 +1,177 @@
+package iamruntimemiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/internal/testauth"
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestTokenExtractor(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	token := authsrv.TSignSubject(t, "some subject")
+
+	chain := ChainTokenExtractor(
+		BearerTokenExtractor(),
+		HeaderTokenExtractor("X-Webhook-Token", ""),
+		CookieTokenExtractor("session"),
+		QueryTokenExtractor("access_token"),
+	)
+
+	testCases := []struct {
+		name           string
+		extractor      TokenExtractor
+		rejectMultiple bool
+		setup          func(req *http.Request)
+		expectStatus   int
+	}{
+		{
+			"bearer",
+			nil,
+			false,
+			func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+token)
+			},
+			http.StatusOK,
+		},
+		{
+			"bearer missing",
+			nil,
+			false,
+			func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: "session", Value: token})
+			},
+			http.StatusUnauthorized,
+		},
+		{
+			"bearer wrong scheme",
+			nil,
+			false,
+			func(req *http.Request) {
+				req.Header.Set("Authorization", "Basic "+token)
+			},
+			http.StatusUnauthorized,
+		},
+		{
+			"header",
+			chain,
+			false,
+			func(req *http.Request) {
+				req.Header.Set("X-Webhook-Token", token)
+			},
+			http.StatusOK,
+		},
+		{
+			"cookie",
+			chain,
+			false,
+			func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: "session", Value: token})
+			},
+			http.StatusOK,
+		},
+		{
+			"query",
+			chain,
+			false,
+			func(req *http.Request) {
+				req.URL.RawQuery = "access_token=" + token
+			},
+			http.StatusOK,
+		},
+		{
+			"multiple allowed",
+			chain,
+			false,
+			func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+token)
+				req.URL.RawQuery = "access_token=invalid"
+			},
+			http.StatusOK,
+		},
+		{
+			"multiple rejected",
+			chain,
+			true,
+			func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+token)
+				req.URL.RawQuery = "access_token=invalid"
+			},
+			http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			runtime.Mock.On("ValidateCredential", "some subject").Return(&authentication.ValidateCredentialResponse{
+				Result: authentication.ValidateCredentialResponse_RESULT_VALID,
+			}, nil).Maybe()
+
+			config := NewConfig().
+				WithRuntime(runtime).
+				WithTokenExtractor(tc.extractor).
+				WithRejectMultipleTokens(tc.rejectMultiple)
+
+			middleware, err := config.ToMiddleware()
+			require.NoError(t, err, "unexpected error building middleware")
+
+			engine := echo.New()
+
+			engine.Use(middleware)
+
+			engine.GET("/test", func(c echo.Context) error {
+				return c.String(http.StatusOK, ContextSubject(c))
+			})
+
+			ctx := context.Background()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+
+			tc.setup(req)
+
+			resp := httptest.NewRecorder()
+
+			engine.ServeHTTP(resp, req)
+
+			runtime.Mock.AssertExpectations(t)
+
+			assert.Equal(t, tc.expectStatus, resp.Code, "unexpected status code returned")
+
+			if tc.expectStatus == http.StatusOK {
+				assert.Equal(t, "some subject", resp.Body.String(), "unexpected body returned")
+			}
+		})
+	}
+}
+
+func ExampleChainTokenExtractor() {
+	extractor := ChainTokenExtractor(
+		BearerTokenExtractor(),
+		CookieTokenExtractor("session"),
+	)
+
+	middleware, _ := NewConfig().WithTokenExtractor(extractor).WithRejectMultipleTokens(true).ToMiddleware()
+
+	engine := echo.New()
+
+	engine.Use(middleware)
+
+	engine.GET("/user", func(c echo.Context) error {
+		return c.String(http.StatusOK, "welcome "+ContextSubject(c))
+	})
+
+	_ = http.ListenAndServe(":8080", engine)
+}
@@ -16,6 +16,10 @@ func (c Config) ToMiddleware() (echo.MiddlewareFunc, error) {
 		c.Skipper = middleware.DefaultSkipper
 	}
 
+	if c.TokenExtractor == nil {
+		c.TokenExtractor = BearerTokenExtractor()
+	}
+
 	c.runtime = c.Runtime
 
 	if c.Runtime == nil {
@@ -43,13 +47,28 @@ func (c Config) ToMiddleware() (echo.MiddlewareFunc, error) {
 				return err
 			}
 
-			if err := setAuthenticationContext(ctx); err != nil {
-				ctx.Error(err)
+			if err := c.setAuthenticationContext(ctx); err != nil {
+				if err = c.handleError(ctx, err); err != nil {
+					ctx.Error(err)
+				}
 
 				return err
 			}
 
-			return next(ctx)
+			var err error
+
+			if c.auditingAccess() {
+				err = c.auditAccess(ctx, next)
+			} else {
+				err = next(ctx)
+			}
+
+			// Errors returned by handlers and route middleware are handled before echo renders them.
+			if err != nil {
+				return c.handleError(ctx, err)
+			}
+
+			return nil
 		}
 	}, nil
 }
@@ -0,0 +1,154 @@
+package iamruntimemiddleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+	"github.com/metal-toolbox/iam-runtime-contrib/internal/jwks"
+)
+
+const (
+	defaultJWKSRefreshInterval    = 15 * time.Minute
+	defaultJWKSMinRefreshInterval = time.Minute
+)
+
+// Prevalidator validates credentials locally before they are validated by the runtime.
+// The runtime still makes the authoritative decision on credentials accepted by the prevalidator.
+type Prevalidator interface {
+	// Prevalidate returns an error wrapping [iamruntime.ErrInvalidCredentials] if the credential should be rejected.
+	// Any other error results in an internal server error.
+	Prevalidate(ctx context.Context, credential *iamruntime.Credential) error
+}
+
+// JWKSConfig defines configuration for a [JWKSPrevalidator].
+type JWKSConfig struct {
+	// Issuer is the required token issuer.
+	// Unless JWKSURI is provided, the JSON Web Key Set uri is discovered from the issuer's OIDC discovery document.
+	Issuer string
+
+	// JWKSURI defines the JSON Web Key Set uri.
+	// Default is discovered from the Issuer.
+	JWKSURI string
+
+	// Audience defines the audiences accepted by the prevalidator.
+	// A token must have at least one of the audiences.
+	// Default is empty, the audience is not validated.
+	Audience []string
+
+	// Leeway defines the allowed clock skew when validating exp and nbf.
+	// Default is 0.
+	Leeway time.Duration
+
+	// RefreshInterval defines how often the key set is refreshed.
+	// Default is 15 minutes.
+	RefreshInterval time.Duration
+
+	// MinRefreshInterval defines how often the key set may be refreshed when a token uses an unknown key.
+	// Default is 1 minute.
+	MinRefreshInterval time.Duration
+
+	// HTTPClient is the client used to fetch the discovery document and key set.
+	// Default is a client with a 10 second timeout.
+	HTTPClient *http.Client
+
+	// RequireKeySet fails requests with an internal server error while the key set has never been fetched successfully.
+	// Default is false, credentials are passed to the runtime without local validation until the key set is fetched.
+	RequireKeySet bool
+}
+
+// JWKSPrevalidator is a [Prevalidator] which validates the jwt signature,
+// exp, nbf, iss and aud claims against a cached JSON Web Key Set.
+// Opaque credentials are not validated.
+type JWKSPrevalidator struct {
+	keys          *jwks.KeySet
+	parser        *jwt.Parser
+	audience      []string
+	requireKeySet bool
+}
+
+// Prevalidate validates the credential token against the key set.
+func (v *JWKSPrevalidator) Prevalidate(ctx context.Context, credential *iamruntime.Credential) error {
+	if credential.IsOpaque() {
+		return nil
+	}
+
+	token, err := v.parser.Parse(credential.Raw, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		return v.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		if errors.Is(err, jwks.ErrFetchFailed) {
+			// The runtime makes the authoritative decision, so the credential is passed on unless the key set is required.
+			if !v.requireKeySet {
+				return nil
+			}
+
+			return fmt.Errorf("%w: %w", iamruntime.ErrKeySetUnavailable, err)
+		}
+
+		return fmt.Errorf("%w: %w", iamruntime.ErrTokenPrevalidationFailed, err)
+	}
+
+	if len(v.audience) != 0 {
+		audience, err := token.Claims.GetAudience()
+		if err != nil {
+			return fmt.Errorf("%w: %w", iamruntime.ErrTokenPrevalidationFailed, err)
+		}
+
+		if !slices.ContainsFunc(v.audience, func(aud string) bool { return slices.Contains(audience, aud) }) {
+			return fmt.Errorf("%w: %w", iamruntime.ErrTokenPrevalidationFailed, jwt.ErrTokenInvalidAudience)
+		}
+	}
+
+	return nil
+}
+
+// NewJWKSPrevalidator creates a new [JWKSPrevalidator] from the provided config.
+// The key set is fetched on first use.
+func NewJWKSPrevalidator(config JWKSConfig) (*JWKSPrevalidator, error) {
+	if config.Issuer == "" && config.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: issuer or jwks uri required", iamruntime.ErrKeySetUnavailable)
+	}
+
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = defaultJWKSRefreshInterval
+	}
+
+	if config.MinRefreshInterval <= 0 {
+		config.MinRefreshInterval = defaultJWKSMinRefreshInterval
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithLeeway(config.Leeway),
+	}
+
+	if config.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(config.Issuer))
+	}
+
+	return &JWKSPrevalidator{
+		keys:          jwks.NewKeySet(config.HTTPClient, config.Issuer, config.JWKSURI, config.RefreshInterval, config.MinRefreshInterval),
+		parser:        jwt.NewParser(parserOpts...),
+		audience:      config.Audience,
+		requireKeySet: config.RequireKeySet,
+	}, nil
+}
+
+// prevalidateError converts a prevalidation error to an echo error with a proper status code.
+func prevalidateError(err error) error {
+	if errors.Is(err, iamruntime.ErrInvalidCredentials) {
+		return echo.ErrUnauthorized.WithInternal(err)
+	}
+
+	return echo.ErrInternalServerError.WithInternal(err)
+}
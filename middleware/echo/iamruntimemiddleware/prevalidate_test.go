@@ -0,0 +1,200 @@
+package iamruntimemiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	josejwt "github.com/go-jose/go-jose/v4/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/internal/testauth"
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestJWKSPrevalidator(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	othersrv := testauth.NewServer(t)
+	t.Cleanup(othersrv.Stop)
+
+	prevalidator, err := NewJWKSPrevalidator(JWKSConfig{
+		Issuer:   authsrv.Issuer,
+		Audience: []string{"https://api.example.com"},
+	})
+	require.NoError(t, err, "unexpected error creating prevalidator")
+
+	audience := testauth.Audience("https://api.example.com")
+
+	testCases := []struct {
+		name          string
+		token         string
+		expectRuntime bool
+		expectStatus  int
+	}{
+		{
+			"valid",
+			authsrv.TSignSubject(t, "some subject", audience, testauth.Expiry(josejwt.NewNumericDate(time.Now().Add(time.Hour)))),
+			true,
+			http.StatusOK,
+		},
+		{
+			"expired",
+			authsrv.TSignSubject(t, "some subject", audience, testauth.Expiry(josejwt.NewNumericDate(time.Now().Add(-time.Hour)))),
+			false,
+			http.StatusUnauthorized,
+		},
+		{
+			"not yet valid",
+			authsrv.TSignSubject(t, "some subject", audience, testauth.NotBefore(josejwt.NewNumericDate(time.Now().Add(time.Hour)))),
+			false,
+			http.StatusUnauthorized,
+		},
+		{
+			"wrong audience",
+			authsrv.TSignSubject(t, "some subject", testauth.Audience("https://other.example.com")),
+			false,
+			http.StatusUnauthorized,
+		},
+		{
+			"wrong issuer",
+			othersrv.TSignSubject(t, "some subject", audience),
+			false,
+			http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			if tc.expectRuntime {
+				runtime.Mock.On("ValidateCredential", "some subject").Return(&authentication.ValidateCredentialResponse{
+					Result: authentication.ValidateCredentialResponse_RESULT_VALID,
+				}, nil)
+			}
+
+			middleware, err := NewConfig().WithRuntime(runtime).WithPrevalidator(prevalidator).ToMiddleware()
+			require.NoError(t, err, "unexpected error building middleware")
+
+			engine := echo.New()
+
+			engine.Use(middleware)
+
+			engine.GET("/test", func(c echo.Context) error {
+				return c.String(http.StatusOK, ContextSubject(c))
+			})
+
+			ctx := context.Background()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+
+			req.Header.Add("Authorization", "Bearer "+tc.token)
+
+			resp := httptest.NewRecorder()
+
+			engine.ServeHTTP(resp, req)
+
+			runtime.Mock.AssertExpectations(t)
+
+			assert.Equal(t, tc.expectStatus, resp.Code, "unexpected status code returned")
+		})
+	}
+}
+
+func TestJWKSPrevalidatorKeySetUnavailable(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	unavailable := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(unavailable.Close)
+
+	testCases := []struct {
+		name          string
+		requireKeySet bool
+		expectRuntime bool
+		expectStatus  int
+	}{
+		{
+			"passed to runtime",
+			false,
+			true,
+			http.StatusOK,
+		},
+		{
+			"key set required",
+			true,
+			false,
+			http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prevalidator, err := NewJWKSPrevalidator(JWKSConfig{
+				Issuer:        authsrv.Issuer,
+				JWKSURI:       unavailable.URL,
+				RequireKeySet: tc.requireKeySet,
+			})
+			require.NoError(t, err, "unexpected error creating prevalidator")
+
+			runtime := new(mockruntime.MockRuntime)
+
+			if tc.expectRuntime {
+				runtime.Mock.On("ValidateCredential", "some subject").Return(&authentication.ValidateCredentialResponse{
+					Result: authentication.ValidateCredentialResponse_RESULT_VALID,
+				}, nil)
+			}
+
+			middleware, err := NewConfig().WithRuntime(runtime).WithPrevalidator(prevalidator).ToMiddleware()
+			require.NoError(t, err, "unexpected error building middleware")
+
+			engine := echo.New()
+
+			engine.Use(middleware)
+
+			engine.GET("/test", func(c echo.Context) error {
+				return c.String(http.StatusOK, ContextSubject(c))
+			})
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+
+			req.Header.Add("Authorization", "Bearer "+authsrv.TSignSubject(t, "some subject"))
+
+			resp := httptest.NewRecorder()
+
+			engine.ServeHTTP(resp, req)
+
+			runtime.Mock.AssertExpectations(t)
+
+			assert.Equal(t, tc.expectStatus, resp.Code, "unexpected status code returned")
+		})
+	}
+}
+
+func ExampleNewJWKSPrevalidator() {
+	prevalidator, _ := NewJWKSPrevalidator(JWKSConfig{
+		Issuer:   "https://iam.example.com",
+		Audience: []string{"https://api.example.com"},
+	})
+
+	middleware, _ := NewConfig().WithPrevalidator(prevalidator).ToMiddleware()
+
+	engine := echo.New()
+
+	engine.Use(middleware)
+
+	engine.GET("/user", func(c echo.Context) error {
+		return c.String(http.StatusOK, "welcome "+ContextSubject(c))
+	})
+
+	_ = http.ListenAndServe(":8080", engine)
+}
@@ -0,0 +1,165 @@
+package iamruntimemiddleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+	"github.com/metal-toolbox/iam-runtime-contrib/internal"
+)
+
+// MIMEApplicationProblemJSON is the content type of RFC 7807 problem details responses.
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// ErrorHandler handles iamruntime errors produced by the middleware, the requirement middlewares
+// and the helpers such as [CheckAccess], [ValidateCredential] and [CreateRelationships].
+//
+// Status is the classified status code and err is the iamruntime error which occurred.
+// The returned error is returned from the middleware, return nil if the response has been written.
+type ErrorHandler func(c echo.Context, status int, err error) error
+
+// ProblemConfig defines configuration for [ProblemErrorHandler].
+type ProblemConfig struct {
+	// TypeBaseURI is the base uri of the problem type uris.
+	// The problem type name is appended to the base uri, for example https://example.com/problems/access-denied.
+	// Default is empty, the problem type is about:blank and the title is the status text.
+	TypeBaseURI string
+
+	// IncludeActions includes the requested resource actions when an access request is denied.
+	// Default is false, actions are not included.
+	IncludeActions bool
+}
+
+// ProblemAction is a resource action included in problem details.
+type ProblemAction struct {
+	// ResourceID is the id of the resource the action was requested on.
+	ResourceID string `json:"resource_id"`
+
+	// Action is the requested action.
+	Action string `json:"action"`
+}
+
+// ProblemDetails is an RFC 7807 problem details response.
+type ProblemDetails struct {
+	// Type is a uri which identifies the problem type.
+	Type string `json:"type"`
+
+	// Title is a short summary of the problem type.
+	Title string `json:"title"`
+
+	// Status is the response status code.
+	Status int `json:"status"`
+
+	// Detail is an explanation of this occurrence of the problem.
+	// Detail is only included if [Config.Development] is set, as it may expose internal error details.
+	Detail string `json:"detail,omitempty"`
+
+	// Instance is the request path which produced the problem.
+	Instance string `json:"instance,omitempty"`
+
+	// Actions are the resource actions of a denied access request.
+	Actions []ProblemAction `json:"actions,omitempty"`
+}
+
+// developmentContextKey is the echo context key set by the middleware when [Config.Development] is set.
+const developmentContextKey = "iamruntimemiddleware.development"
+
+// problemType maps an iamruntime error to a problem type name and title.
+type problemType struct {
+	err   error
+	name  string
+	title string
+}
+
+// problemTypes are matched in order, so more specific errors must come before the errors they wrap.
+var problemTypes = []problemType{
+	{internal.ErrInvalidAuthToken, "authentication-required", "Authentication Required"},
+	{iamruntime.ErrTokenNotFound, "authentication-required", "Authentication Required"},
+	{iamruntime.ErrMultipleTokens, "multiple-tokens", "Multiple Tokens Provided"},
+	{iamruntime.ErrCredentialRevoked, "credential-revoked", "Credential Revoked"},
+	{iamruntime.ErrInvalidCredentials, "invalid-credentials", "Invalid Credentials"},
+	{iamruntime.ErrClaimsInvalid, "invalid-claims", "Invalid Claims"},
+	{iamruntime.ErrInsufficientScope, "insufficient-scope", "Insufficient Scope"},
+	{iamruntime.ErrAccessDenied, "access-denied", "Access Denied"},
+	{iamruntime.ErrResourceIDActionPairsInvalid, "invalid-access-request", "Invalid Access Request"},
+	{iamruntime.ErrAccessNotChecked, "access-not-checked", "Access Not Checked"},
+	{iamruntime.ErrRelationshipInvalid, "invalid-relationship", "Invalid Relationship"},
+	{iamruntime.ErrRelationshipConflict, "relationship-conflict", "Relationship Already Exists"},
+	{iamruntime.ErrRelationshipNotFound, "relationship-not-found", "Relationship Not Found"},
+	{iamruntime.ErrRelationshipPermissionDenied, "relationship-permission-denied", "Relationship Permission Denied"},
+	{iamruntime.AuthError, "authentication-failed", "Authentication Failed"},
+	{iamruntime.Error, "runtime-error", "IAM Runtime Error"},
+}
+
+// ProblemErrorHandler returns an [ErrorHandler] which renders errors as RFC 7807 application/problem+json responses.
+// The error message is included in the detail member only if [Config.Development] is set.
+func ProblemErrorHandler(config ProblemConfig) ErrorHandler {
+	return func(c echo.Context, status int, err error) error {
+		problem := ProblemDetails{
+			Type:     "about:blank",
+			Title:    http.StatusText(status),
+			Status:   status,
+			Instance: c.Request().URL.Path,
+		}
+
+		if config.TypeBaseURI != "" {
+			for _, pType := range problemTypes {
+				if errors.Is(err, pType.err) {
+					problem.Type = strings.TrimSuffix(config.TypeBaseURI, "/") + "/" + pType.name
+					problem.Title = pType.title
+
+					break
+				}
+			}
+		}
+
+		if development, _ := c.Get(developmentContextKey).(bool); development {
+			problem.Detail = err.Error()
+		}
+
+		var deniedErr *iamruntime.AccessDeniedError
+
+		if config.IncludeActions && errors.As(err, &deniedErr) {
+			for _, action := range deniedErr.Actions {
+				problem.Actions = append(problem.Actions, ProblemAction{
+					ResourceID: action.GetResourceId(),
+					Action:     action.GetAction(),
+				})
+			}
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+
+		if c.Request().Method == http.MethodHead {
+			return c.NoContent(status)
+		}
+
+		return c.JSON(status, problem)
+	}
+}
+
+// handleError sets the WWW-Authenticate challenge for the error and passes iamruntime errors to the ErrorHandler.
+// If no ErrorHandler is configured or the response has already been written, the error is returned unchanged.
+func (c Config) handleError(ctx echo.Context, err error) error {
+	c.setAuthenticateHeader(ctx, err)
+
+	var httpErr *echo.HTTPError
+
+	if c.ErrorHandler == nil || ctx.Response().Committed || !errors.As(err, &httpErr) || !errors.Is(err, iamruntime.Error) {
+		return err
+	}
+
+	runtimeErr := httpErr.Internal
+	if runtimeErr == nil {
+		runtimeErr = err
+	}
+
+	if c.Development {
+		ctx.Set(developmentContextKey, true)
+	}
+
+	return c.ErrorHandler(ctx, httpErr.Code, runtimeErr)
+}
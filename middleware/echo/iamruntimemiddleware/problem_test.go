@@ -0,0 +1,180 @@
+package iamruntimemiddleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestProblemErrorHandler(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"sub": "some subject",
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		config       ProblemConfig
+		development  bool
+		authenticate bool
+		accessResult authorization.CheckAccessResponse_Result
+		accessError  error
+		expectStatus int
+		expectBody   map[string]any
+	}{
+		{
+			"missing token",
+			ProblemConfig{TypeBaseURI: "https://example.com/problems/"},
+			true,
+			false,
+			0,
+			nil,
+			http.StatusUnauthorized,
+			map[string]any{
+				"type":     "https://example.com/problems/authentication-required",
+				"title":    "Authentication Required",
+				"status":   float64(http.StatusUnauthorized),
+				"detail":   "iam-runtime error: auth: invalid auth token",
+				"instance": "/test",
+			},
+		},
+		{
+			"denied with actions",
+			ProblemConfig{TypeBaseURI: "https://example.com/problems", IncludeActions: true},
+			true,
+			true,
+			authorization.CheckAccessResponse_RESULT_DENIED,
+			nil,
+			http.StatusForbidden,
+			map[string]any{
+				"type":     "https://example.com/problems/access-denied",
+				"title":    "Access Denied",
+				"status":   float64(http.StatusForbidden),
+				"detail":   "iam-runtime error: access: denied",
+				"instance": "/test",
+				"actions": []any{
+					map[string]any{"resource_id": "testten-abc123", "action": "action_one"},
+				},
+			},
+		},
+		{
+			"denied without type base uri",
+			ProblemConfig{},
+			false,
+			true,
+			authorization.CheckAccessResponse_RESULT_DENIED,
+			nil,
+			http.StatusForbidden,
+			map[string]any{
+				"type":     "about:blank",
+				"title":    "Forbidden",
+				"status":   float64(http.StatusForbidden),
+				"instance": "/test",
+			},
+		},
+		{
+			"runtime error without development",
+			ProblemConfig{TypeBaseURI: "https://example.com/problems"},
+			false,
+			true,
+			0,
+			grpc.ErrServerStopped,
+			http.StatusInternalServerError,
+			map[string]any{
+				"type":     "https://example.com/problems/runtime-error",
+				"title":    "IAM Runtime Error",
+				"status":   float64(http.StatusInternalServerError),
+				"instance": "/test",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			if tc.authenticate {
+				runtime.Mock.On("ValidateCredential", "some subject").Return(&authentication.ValidateCredentialResponse{
+					Result: authentication.ValidateCredentialResponse_RESULT_VALID,
+				}, nil)
+
+				runtime.Mock.On("CheckAccess", map[string][]string{"testten-abc123": {"action_one"}}).Return(tc.accessResult, tc.accessError)
+			}
+
+			middleware, err := NewConfig().WithRuntime(runtime).WithErrorHandler(ProblemErrorHandler(tc.config)).WithDevelopment(tc.development).ToMiddleware()
+			require.NoError(t, err, "unexpected error building middleware")
+
+			engine := echo.New()
+
+			engine.Use(middleware)
+
+			engine.GET("/test", func(c echo.Context) error {
+				if err := CheckAccessTo(c, "testten-abc123", "action_one"); err != nil {
+					return err
+				}
+
+				return c.String(http.StatusOK, "success")
+			})
+
+			ctx := context.Background()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+
+			if tc.authenticate {
+				req.Header.Add("Authorization", "Bearer "+token)
+			}
+
+			resp := httptest.NewRecorder()
+
+			engine.ServeHTTP(resp, req)
+
+			runtime.Mock.AssertExpectations(t)
+
+			assert.Equal(t, tc.expectStatus, resp.Code, "unexpected status code returned")
+			assert.Equal(t, MIMEApplicationProblemJSON, resp.Header().Get(echo.HeaderContentType), "unexpected content type returned")
+
+			var body map[string]any
+
+			err = json.Unmarshal(resp.Body.Bytes(), &body)
+			require.NoError(t, err, "unexpected error decoding body")
+
+			assert.Equal(t, tc.expectBody, body, "unexpected body returned")
+		})
+	}
+}
+
+func ExampleProblemErrorHandler() {
+	middleware, _ := NewConfig().
+		WithErrorHandler(ProblemErrorHandler(ProblemConfig{
+			TypeBaseURI:    "https://example.com/problems",
+			IncludeActions: true,
+		})).
+		ToMiddleware()
+
+	engine := echo.New()
+
+	engine.Use(middleware)
+
+	engine.GET("/resources/:id", func(c echo.Context) error {
+		if err := CheckAccessTo(c, c.Param("id"), "resource_get"); err != nil {
+			return err
+		}
+
+		return c.String(http.StatusOK, "resource "+c.Param("id"))
+	})
+
+	_ = http.ListenAndServe(":8080", engine)
+}
@@ -0,0 +1,264 @@
+package iamruntimemiddleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+// IDExtractor extracts a resource id from a request or its response.
+type IDExtractor interface {
+	// ExtractID returns the id found in the request or the buffered response body.
+	// If no id is found, an empty string is returned.
+	ExtractID(c echo.Context, body []byte) (string, error)
+}
+
+// IDExtractorFunc adapts a function to an [IDExtractor].
+type IDExtractorFunc func(c echo.Context, body []byte) (string, error)
+
+// ExtractID calls f(c, body).
+func (f IDExtractorFunc) ExtractID(c echo.Context, body []byte) (string, error) {
+	return f(c, body)
+}
+
+// ParamIDExtractor returns an [IDExtractor] which extracts the id from the named path parameter.
+func ParamIDExtractor(name string) IDExtractor {
+	return IDExtractorFunc(func(c echo.Context, _ []byte) (string, error) {
+		return c.Param(name), nil
+	})
+}
+
+// QueryIDExtractor returns an [IDExtractor] which extracts the id from the named query parameter.
+func QueryIDExtractor(name string) IDExtractor {
+	return IDExtractorFunc(func(c echo.Context, _ []byte) (string, error) {
+		return c.QueryParam(name), nil
+	})
+}
+
+// HeaderIDExtractor returns an [IDExtractor] which extracts the id from the named request header.
+func HeaderIDExtractor(name string) IDExtractor {
+	return IDExtractorFunc(func(c echo.Context, _ []byte) (string, error) {
+		return c.Request().Header.Get(name), nil
+	})
+}
+
+// ResponseHeaderIDExtractor returns an [IDExtractor] which extracts the id from the named response header.
+func ResponseHeaderIDExtractor(name string) IDExtractor {
+	return IDExtractorFunc(func(c echo.Context, _ []byte) (string, error) {
+		return c.Response().Header().Get(name), nil
+	})
+}
+
+// ContextIDExtractor returns an [IDExtractor] which extracts the id from the named echo context value.
+// Handlers may set the value with c.Set, for example with the parent of a deleted resource.
+func ContextIDExtractor(key string) IDExtractor {
+	return IDExtractorFunc(func(c echo.Context, _ []byte) (string, error) {
+		id, _ := c.Get(key).(string)
+
+		return id, nil
+	})
+}
+
+// SubjectIDExtractor returns an [IDExtractor] which extracts the id of the authenticated subject.
+func SubjectIDExtractor() IDExtractor {
+	return IDExtractorFunc(func(c echo.Context, _ []byte) (string, error) {
+		return ContextSubject(c), nil
+	})
+}
+
+// ResponseJSONIDExtractor returns an [IDExtractor] which extracts the id from the JSON response body.
+// The path is the field names leading to the id, for example "data", "id" for {"data": {"id": "..."}}.
+func ResponseJSONIDExtractor(path ...string) IDExtractor {
+	return IDExtractorFunc(func(_ echo.Context, body []byte) (string, error) {
+		if len(body) == 0 {
+			return "", nil
+		}
+
+		var value any
+
+		if err := json.Unmarshal(body, &value); err != nil {
+			return "", fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		for _, field := range path {
+			object, ok := value.(map[string]any)
+			if !ok {
+				return "", nil
+			}
+
+			value = object[field]
+		}
+
+		switch id := value.(type) {
+		case nil:
+			return "", nil
+		case string:
+			return id, nil
+		default:
+			return "", fmt.Errorf("%w: response field %s is not a string", iamruntime.ErrRelationshipIDInvalid, strings.Join(path, "."))
+		}
+	})
+}
+
+// RelationshipHook defines the relationships written when a request completes successfully.
+// Build the echo middleware by calling [RelationshipHook.ToCreateMiddleware] or [RelationshipHook.ToDeleteMiddleware].
+type RelationshipHook struct {
+	// ResourceID extracts the id of the resource the relationships are from.
+	ResourceID IDExtractor
+
+	// Parent extracts the id of the resource's parent.
+	// Default is nil, no parent relationship is written.
+	Parent IDExtractor
+
+	// Owner extracts the id of the resource's owner.
+	// See [SubjectIDExtractor] to use the authenticated subject.
+	// Default is nil, no owner relationship is written.
+	Owner IDExtractor
+
+	// CallOptions are the grpc call options used for the relationship request.
+	CallOptions []grpc.CallOption
+}
+
+// WithParent returns a new RelationshipHook with Parent set to the provided value.
+func (h RelationshipHook) WithParent(value IDExtractor) RelationshipHook {
+	h.Parent = value
+
+	return h
+}
+
+// WithOwner returns a new RelationshipHook with Owner set to the provided value.
+func (h RelationshipHook) WithOwner(value IDExtractor) RelationshipHook {
+	h.Owner = value
+
+	return h
+}
+
+// WithCallOptions returns a new RelationshipHook with CallOptions set to the provided value.
+func (h RelationshipHook) WithCallOptions(value ...grpc.CallOption) RelationshipHook {
+	h.CallOptions = value
+
+	return h
+}
+
+// ToCreateMiddleware builds a middleware which creates the relationships after the handler responds successfully.
+//
+// See [RelationshipHook.ToDeleteMiddleware] for details on how responses are handled.
+func (h RelationshipHook) ToCreateMiddleware() echo.MiddlewareFunc {
+	return h.toMiddleware(iamruntime.RelationshipCreate)
+}
+
+// ToDeleteMiddleware builds a middleware which deletes the relationships after the handler responds successfully.
+//
+// The response is buffered until the handler returns. If the handler returns an error or a non 2xx response,
+// the response is sent and no relationships are written. Otherwise the ids are extracted
+// and the relationships written before the response is sent. If extracting the ids or writing the relationships fails,
+// the response is discarded and an error is returned instead.
+// Relationships with an empty parent or owner id are skipped.
+//
+// The runtime middleware built by [Config.ToMiddleware] must run before this middleware.
+func (h RelationshipHook) ToDeleteMiddleware() echo.MiddlewareFunc {
+	return h.toMiddleware(iamruntime.RelationshipDelete)
+}
+
+func (h RelationshipHook) toMiddleware(operation iamruntime.RelationshipOperation) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			buffer := bufferResponse(c.Response())
+
+			err := next(c)
+
+			status := buffer.Status()
+
+			if err != nil || status < http.StatusOK || status >= http.StatusMultipleChoices {
+				return flushResponse(buffer, err)
+			}
+
+			if err = h.write(c, operation, buffer.Body()); err != nil {
+				buffer.discard()
+
+				return err
+			}
+
+			return flushResponse(buffer, nil)
+		}
+	}
+}
+
+// write extracts the relationships of the request and applies the operation to them.
+func (h RelationshipHook) write(c echo.Context, operation iamruntime.RelationshipOperation, body []byte) error {
+	resourceID, err := h.extract(c, h.ResourceID, body)
+	if err != nil {
+		return err
+	}
+
+	parentID, err := h.extract(c, h.Parent, body)
+	if err != nil {
+		return err
+	}
+
+	ownerID, err := h.extract(c, h.Owner, body)
+	if err != nil {
+		return err
+	}
+
+	if parentID == "" && ownerID == "" {
+		return nil
+	}
+
+	if resourceID == "" {
+		return echo.ErrInternalServerError.WithInternal(fmt.Errorf("%w: resource id not found", iamruntime.ErrRelationshipIDInvalid))
+	}
+
+	relationships := iamruntime.Relate(resourceID)
+
+	if parentID != "" {
+		relationships.Parent(parentID)
+	}
+
+	if ownerID != "" {
+		relationships.Owner(ownerID)
+	}
+
+	write := iamruntime.RelationshipWrite{
+		Operation:     operation,
+		ResourceID:    resourceID,
+		Relationships: relationships.Relationships(),
+	}
+
+	if err = write.Validate(); err == nil {
+		err = write.Apply(c.Request().Context(), h.CallOptions...)
+	}
+
+	if err != nil {
+		return relationshipError(err)
+	}
+
+	return nil
+}
+
+// extract returns the id found by the extractor. If the extractor is nil, an empty string is returned.
+func (h RelationshipHook) extract(c echo.Context, extractor IDExtractor, body []byte) (string, error) {
+	if extractor == nil {
+		return "", nil
+	}
+
+	id, err := extractor.ExtractID(c, body)
+	if err != nil {
+		return "", echo.ErrInternalServerError.WithInternal(fmt.Errorf("%w: %w", iamruntime.RelationshipError, err))
+	}
+
+	return id, nil
+}
+
+// NewRelationshipHook returns a new RelationshipHook for the resource id found by the provided extractor.
+func NewRelationshipHook(resourceID IDExtractor) RelationshipHook {
+	return RelationshipHook{
+		ResourceID: resourceID,
+	}
+}
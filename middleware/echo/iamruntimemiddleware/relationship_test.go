@@ -0,0 +1,272 @@
+package iamruntimemiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+	"github.com/metal-toolbox/iam-runtime-contrib/internal/testauth"
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestRelationshipHook(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	created := map[string][]string{
+		"parent": {"tnntten-root123"},
+		"owner":  {"idntusr-owner1"},
+	}
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		handlerStatus  int
+		handlerBody    string
+		expectCall     string
+		expectCalled   map[string][]string
+		returnError    error
+		expectStatus   int
+		expectContains string
+	}{
+		{
+			"created",
+			http.MethodPost,
+			"/tenants/tnntten-root123/loadbalancers",
+			http.StatusCreated,
+			`{"id":"loadbal-abc123"}`,
+			"CreateRelationships",
+			created,
+			nil,
+			http.StatusCreated,
+			`{"id":"loadbal-abc123"}`,
+		},
+		{
+			"handler failed",
+			http.MethodPost,
+			"/tenants/tnntten-root123/loadbalancers",
+			http.StatusBadRequest,
+			`{"message":"invalid load balancer"}`,
+			"",
+			nil,
+			nil,
+			http.StatusBadRequest,
+			"invalid load balancer",
+		},
+		{
+			"relationship conflict",
+			http.MethodPost,
+			"/tenants/tnntten-root123/loadbalancers",
+			http.StatusCreated,
+			`{"id":"loadbal-abc123"}`,
+			"CreateRelationships",
+			created,
+			status.Error(codes.AlreadyExists, "already exists"),
+			http.StatusConflict,
+			"relationship already exists",
+		},
+		{
+			"invalid resource id",
+			http.MethodPost,
+			"/tenants/tnntten-root123/loadbalancers",
+			http.StatusCreated,
+			`{"id":"abc123"}`,
+			"",
+			nil,
+			nil,
+			http.StatusInternalServerError,
+			"invalid id",
+		},
+		{
+			"missing resource id",
+			http.MethodPost,
+			"/tenants/tnntten-root123/loadbalancers",
+			http.StatusCreated,
+			`{}`,
+			"",
+			nil,
+			nil,
+			http.StatusInternalServerError,
+			"resource id not found",
+		},
+		{
+			"deleted",
+			http.MethodDelete,
+			"/loadbalancers/loadbal-abc123",
+			http.StatusNoContent,
+			"",
+			"DeleteRelationships",
+			map[string][]string{"parent": {"tnntten-root123"}},
+			nil,
+			http.StatusNoContent,
+			"",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			runtime.Mock.On("ValidateCredential", "idntusr-owner1").Return(&authentication.ValidateCredentialResponse{
+				Result: authentication.ValidateCredentialResponse_RESULT_VALID,
+			}, nil)
+
+			if tc.expectCall != "" {
+				runtime.Mock.On(tc.expectCall, "loadbal-abc123", tc.expectCalled).Return(tc.returnError).Once()
+			}
+
+			middleware, err := NewConfig().WithRuntime(runtime).ToMiddleware()
+			require.NoError(t, err, "unexpected error building middleware")
+
+			engine := echo.New()
+
+			engine.Debug = true
+
+			engine.Use(middleware)
+
+			handler := func(c echo.Context) error {
+				c.Set("parent_id", "tnntten-root123")
+
+				if tc.handlerBody == "" {
+					return c.NoContent(tc.handlerStatus)
+				}
+
+				return c.JSONBlob(tc.handlerStatus, []byte(tc.handlerBody))
+			}
+
+			createHook := NewRelationshipHook(ResponseJSONIDExtractor("id")).
+				WithParent(ParamIDExtractor("tenant_id")).
+				WithOwner(SubjectIDExtractor())
+
+			deleteHook := NewRelationshipHook(ParamIDExtractor("id")).
+				WithParent(ContextIDExtractor("parent_id"))
+
+			engine.POST("/tenants/:tenant_id/loadbalancers", handler, createHook.ToCreateMiddleware())
+			engine.DELETE("/loadbalancers/:id", handler, deleteHook.ToDeleteMiddleware())
+
+			req, err := http.NewRequestWithContext(context.Background(), tc.method, tc.path, nil)
+			require.NoError(t, err)
+
+			req.Header.Add("Authorization", "Bearer "+authsrv.TSignSubject(t, "idntusr-owner1"))
+
+			resp := httptest.NewRecorder()
+
+			engine.ServeHTTP(resp, req)
+
+			runtime.Mock.AssertExpectations(t)
+
+			assert.Equal(t, tc.expectStatus, resp.Code, "unexpected status code returned")
+			assert.Contains(t, resp.Body.String(), tc.expectContains, "unexpected body returned")
+		})
+	}
+}
+
+func TestResponseJSONIDExtractor(t *testing.T) {
+	testCases := []struct {
+		name        string
+		path        []string
+		body        string
+		expectID    string
+		expectError error
+	}{
+		{
+			"top level",
+			[]string{"id"},
+			`{"id":"loadbal-abc123"}`,
+			"loadbal-abc123",
+			nil,
+		},
+		{
+			"nested",
+			[]string{"data", "id"},
+			`{"data":{"id":"loadbal-abc123"}}`,
+			"loadbal-abc123",
+			nil,
+		},
+		{
+			"missing",
+			[]string{"data", "id"},
+			`{"id":"loadbal-abc123"}`,
+			"",
+			nil,
+		},
+		{
+			"empty body",
+			[]string{"id"},
+			``,
+			"",
+			nil,
+		},
+		{
+			"not a string",
+			[]string{"id"},
+			`{"id":123}`,
+			"",
+			iamruntime.ErrRelationshipIDInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			id, err := ResponseJSONIDExtractor(tc.path...).ExtractID(nil, []byte(tc.body))
+
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError, "unexpected error returned")
+
+				return
+			}
+
+			require.NoError(t, err, "unexpected error returned")
+			assert.Equal(t, tc.expectID, id, "unexpected id returned")
+		})
+	}
+}
+
+func ExampleRelationshipHook() {
+	middleware, _ := NewConfig().ToMiddleware()
+
+	engine := echo.New()
+
+	engine.Use(middleware)
+
+	createRelationships := NewRelationshipHook(ResponseJSONIDExtractor("id")).
+		WithParent(ParamIDExtractor("tenant_id")).
+		WithOwner(SubjectIDExtractor()).
+		ToCreateMiddleware()
+
+	engine.POST("/tenants/:tenant_id/resources", func(c echo.Context) error {
+		resource := CreateResourceFromRequest(c)
+
+		return c.JSON(http.StatusCreated, echo.Map{
+			"id": resource.ID,
+		})
+	}, createRelationships)
+
+	deleteRelationships := NewRelationshipHook(ParamIDExtractor("resource_id")).
+		WithParent(ContextIDExtractor("parent_id")).
+		ToDeleteMiddleware()
+
+	engine.DELETE("/resources/:resource_id", func(c echo.Context) error {
+		resource := GetResourceFromRequest(c)
+
+		if err := DeleteResourceFromRequest(c); err != nil {
+			return err
+		}
+
+		c.Set("parent_id", resource.ParentResourceID)
+
+		return c.NoContent(http.StatusNoContent)
+	}, deleteRelationships)
+
+	_ = http.ListenAndServe(":8080", engine)
+}
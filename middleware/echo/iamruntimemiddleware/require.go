@@ -0,0 +1,78 @@
+package iamruntimemiddleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+// RequireScopes returns a middleware which ensures the request credential has the required scopes.
+// If the scopes do not match, a forbidden error is returned.
+//
+// See [iamruntime.RequireScopes] for more details.
+func RequireScopes(mode iamruntime.MatchMode, scopes ...string) echo.MiddlewareFunc {
+	return requirement(func(ctx context.Context) error {
+		return iamruntime.RequireScopes(ctx, mode, scopes...)
+	})
+}
+
+// RequireAudience returns a middleware which ensures the request credential has the required audiences.
+// If the audiences do not match, a forbidden error is returned.
+//
+// See [iamruntime.RequireAudience] for more details.
+func RequireAudience(mode iamruntime.MatchMode, audiences ...string) echo.MiddlewareFunc {
+	return requirement(func(ctx context.Context) error {
+		return iamruntime.RequireAudience(ctx, mode, audiences...)
+	})
+}
+
+// RequireIssuer returns a middleware which ensures the request credential was issued by one of the provided issuers.
+// If the issuer does not match, a forbidden error is returned.
+//
+// See [iamruntime.RequireIssuer] for more details.
+func RequireIssuer(issuers ...string) echo.MiddlewareFunc {
+	return requirement(func(ctx context.Context) error {
+		return iamruntime.RequireIssuer(ctx, issuers...)
+	})
+}
+
+// RequireClaim returns a middleware which ensures the named claim of the request credential satisfies the predicate.
+// If the claim is not present or the predicate returns false, a forbidden error is returned.
+//
+// See [iamruntime.RequireClaim] for more details.
+func RequireClaim(name string, predicate func(value any) bool) echo.MiddlewareFunc {
+	return requirement(func(ctx context.Context) error {
+		return iamruntime.RequireClaim(ctx, name, predicate)
+	})
+}
+
+// requirement builds a middleware which rejects requests that do not satisfy the provided check.
+func requirement(check func(ctx context.Context) error) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := check(c.Request().Context()); err != nil {
+				return requirementError(err)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// requirementError converts a requirement error to an echo error with a proper status code.
+func requirementError(err error) error {
+	switch {
+	case errors.Is(err, iamruntime.ErrTokenNotFound):
+		return echo.ErrBadRequest.WithInternal(err)
+	case errors.Is(err, iamruntime.ErrClaimsInvalid):
+		return echo.ErrUnauthorized.WithInternal(err)
+	case errors.Is(err, iamruntime.ErrAccessDenied):
+		return echo.ErrForbidden.WithInternal(err)
+	default:
+		return echo.ErrInternalServerError.WithInternal(fmt.Errorf("unknown error: %w", err))
+	}
+}
@@ -0,0 +1,109 @@
+package iamruntimemiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestRequireScopes(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"sub":   "some subject",
+		"iss":   "https://iam.example.com",
+		"aud":   "https://api.example.com",
+		"scope": "lb:read lb:write",
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		middleware   echo.MiddlewareFunc
+		expectStatus int
+	}{
+		{
+			"scopes permitted",
+			RequireScopes(iamruntime.MatchAll, "lb:read", "lb:write"),
+			http.StatusOK,
+		},
+		{
+			"scopes denied",
+			RequireScopes(iamruntime.MatchAny, "lb:delete"),
+			http.StatusForbidden,
+		},
+		{
+			"audience permitted",
+			RequireAudience(iamruntime.MatchAny, "https://api.example.com"),
+			http.StatusOK,
+		},
+		{
+			"issuer denied",
+			RequireIssuer("https://other.example.com"),
+			http.StatusForbidden,
+		},
+		{
+			"claim permitted",
+			RequireClaim("sub", func(value any) bool { return value == "some subject" }),
+			http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			runtime.Mock.On("ValidateCredential", "some subject").Return(&authentication.ValidateCredentialResponse{
+				Result: authentication.ValidateCredentialResponse_RESULT_VALID,
+			}, nil)
+
+			middleware, err := NewConfig().WithRuntime(runtime).WithStrictAccessCheck(StrictAccessCheckError).WithDevelopment(true).ToMiddleware()
+			require.NoError(t, err, "unexpected error building middleware")
+
+			engine := echo.New()
+
+			engine.Use(middleware)
+
+			engine.GET("/test", func(c echo.Context) error {
+				return c.String(http.StatusOK, "success")
+			}, tc.middleware)
+
+			ctx := context.Background()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+
+			req.Header.Add("Authorization", "Bearer "+token)
+
+			resp := httptest.NewRecorder()
+
+			engine.ServeHTTP(resp, req)
+
+			runtime.Mock.AssertExpectations(t)
+
+			assert.Equal(t, tc.expectStatus, resp.Code, "unexpected status code returned")
+		})
+	}
+}
+
+func ExampleRequireScopes() {
+	middleware, _ := NewConfig().ToMiddleware()
+
+	engine := echo.New()
+
+	engine.Use(middleware)
+
+	engine.POST("/loadbalancers", func(c echo.Context) error {
+		return c.String(http.StatusCreated, "load balancer created")
+	}, RequireScopes(iamruntime.MatchAll, "lb:write"))
+
+	_ = http.ListenAndServe(":8080", engine)
+}
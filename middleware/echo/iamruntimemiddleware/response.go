@@ -0,0 +1,111 @@
+package iamruntimemiddleware
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// responseBuffer captures the response written by a handler so it may be inspected before being sent to the client.
+type responseBuffer struct {
+	response *echo.Response
+	writer   http.ResponseWriter
+
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header returns the buffered response headers.
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader records the response status code.
+func (b *responseBuffer) WriteHeader(code int) {
+	if b.status == 0 {
+		b.status = code
+	}
+}
+
+// Write appends to the buffered response body.
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+
+	return b.body.Write(p)
+}
+
+// Flush is a no-op as the response is held until the handler returns.
+func (b *responseBuffer) Flush() {}
+
+// Unwrap returns the original response writer.
+func (b *responseBuffer) Unwrap() http.ResponseWriter {
+	return b.writer
+}
+
+// Status returns the buffered status code.
+// If nothing has been written, the status of the echo response is returned.
+func (b *responseBuffer) Status() int {
+	if b.status == 0 {
+		return b.response.Status
+	}
+
+	return b.status
+}
+
+// Body returns the buffered response body.
+func (b *responseBuffer) Body() []byte {
+	return b.body.Bytes()
+}
+
+// flush writes the buffered response to the original response writer.
+func (b *responseBuffer) flush() error {
+	b.response.Writer = b.writer
+
+	if b.status == 0 {
+		return nil
+	}
+
+	header := b.writer.Header()
+
+	for key := range header {
+		if _, ok := b.header[key]; !ok {
+			header.Del(key)
+		}
+	}
+
+	for key, values := range b.header {
+		header[key] = values
+	}
+
+	b.writer.WriteHeader(b.status)
+
+	_, err := b.writer.Write(b.body.Bytes())
+
+	return err
+}
+
+// discard drops the buffered response and resets the echo response so a new response may be written.
+func (b *responseBuffer) discard() {
+	b.response.Writer = b.writer
+	b.response.Committed = false
+	b.response.Status = http.StatusOK
+	b.response.Size = 0
+}
+
+// bufferResponse replaces the echo response writer with a buffer.
+// The buffer must be either flushed or discarded once the handler returns.
+func bufferResponse(response *echo.Response) *responseBuffer {
+	buffer := &responseBuffer{
+		response: response,
+		writer:   response.Writer,
+		header:   response.Header().Clone(),
+	}
+
+	response.Writer = buffer
+
+	return buffer
+}
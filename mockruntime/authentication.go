@@ -9,8 +9,9 @@ import (
 )
 
 // ValidateCredential mocks iam-runtime authentication.ValidateCredential
+// The mock is called with the subject of jwt credentials, or the raw credential for opaque credentials.
 func (r *MockRuntime) ValidateCredential(_ context.Context, in *authentication.ValidateCredentialRequest, _ ...grpc.CallOption) (*authentication.ValidateCredentialResponse, error) {
-	var subject string
+	subject := in.Credential
 
 	token, _, err := jwt.NewParser().ParseUnverified(in.Credential, jwt.MapClaims{})
 	if err == nil {
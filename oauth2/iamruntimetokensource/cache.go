@@ -0,0 +1,185 @@
+package iamruntimetokensource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+// Cache persists tokens so they may be reused by later processes.
+type Cache interface {
+	// Load returns the cached token.
+	// If no token is cached, nil is returned.
+	Load(ctx context.Context) (*oauth2.Token, error)
+
+	// Store replaces the cached token.
+	Store(ctx context.Context, token *oauth2.Token) error
+}
+
+// Codec encodes tokens for storage.
+// Implement Codec to encrypt tokens before they are written.
+type Codec interface {
+	// Encode returns the stored form of the token.
+	Encode(token *oauth2.Token) ([]byte, error)
+
+	// Decode returns the token from its stored form.
+	Decode(data []byte) (*oauth2.Token, error)
+}
+
+// cachedToken is the JSON representation of a cached token.
+type cachedToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	Expiry      int64  `json:"expiry,omitempty"`
+}
+
+type jsonCodec struct{}
+
+// JSONCodec returns a [Codec] which stores tokens as plain JSON.
+// This is the default codec.
+func JSONCodec() Codec {
+	return jsonCodec{}
+}
+
+// Encode encodes the token as JSON.
+func (jsonCodec) Encode(token *oauth2.Token) ([]byte, error) {
+	cached := cachedToken{
+		AccessToken: token.AccessToken,
+		TokenType:   token.TokenType,
+	}
+
+	if !token.Expiry.IsZero() {
+		cached.Expiry = token.Expiry.Unix()
+	}
+
+	return json.Marshal(cached)
+}
+
+// Decode decodes the token from JSON.
+func (jsonCodec) Decode(data []byte) (*oauth2.Token, error) {
+	var cached cachedToken
+
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{
+		AccessToken: cached.AccessToken,
+		TokenType:   cached.TokenType,
+	}
+
+	if cached.Expiry != 0 {
+		token.Expiry = time.Unix(cached.Expiry, 0)
+	}
+
+	return token, nil
+}
+
+var _ Cache = (*FileCache)(nil)
+
+// FileCache is a [Cache] which stores the token in a file readable only by the current user.
+// The file is replaced atomically, so concurrent processes never read a partially written token.
+type FileCache struct {
+	path  string
+	codec Codec
+}
+
+// Path returns the path of the cache file.
+func (c *FileCache) Path() string {
+	return c.path
+}
+
+// Load reads the token from the file.
+// If the file does not exist, nil is returned.
+func (c *FileCache) Load(_ context.Context) (*oauth2.Token, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("%w: %w", iamruntime.ErrTokenCacheFailed, err)
+	}
+
+	token, err := c.codec.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", iamruntime.ErrTokenCacheFailed, c.path, err)
+	}
+
+	return token, nil
+}
+
+// Store writes the token to the file, creating the directory if it does not exist.
+// The directory is created with 0700 permissions and the file with 0600 permissions.
+func (c *FileCache) Store(_ context.Context, token *oauth2.Token) error {
+	data, err := c.codec.Encode(token)
+	if err != nil {
+		return fmt.Errorf("%w: %w", iamruntime.ErrTokenCacheFailed, err)
+	}
+
+	dir := filepath.Dir(c.path)
+
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("%w: %w", iamruntime.ErrTokenCacheFailed, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("%w: %w", iamruntime.ErrTokenCacheFailed, err)
+	}
+
+	defer os.Remove(tmp.Name()) //nolint:errcheck // temp file is renamed on success.
+
+	_, err = tmp.Write(data)
+
+	err = errors.Join(err, tmp.Chmod(0o600), tmp.Sync(), tmp.Close())
+	if err != nil {
+		return fmt.Errorf("%w: %w", iamruntime.ErrTokenCacheFailed, err)
+	}
+
+	if err = os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("%w: %w", iamruntime.ErrTokenCacheFailed, err)
+	}
+
+	return nil
+}
+
+// NewFileCache returns a new [FileCache] storing the token at the provided path.
+// If codec is nil, [JSONCodec] is used.
+func NewFileCache(path string, codec Codec) *FileCache {
+	if codec == nil {
+		codec = JSONCodec()
+	}
+
+	return &FileCache{
+		path:  path,
+		codec: codec,
+	}
+}
+
+// NewUserFileCache returns a new [FileCache] storing the token in the user's cache directory,
+// see [os.UserCacheDir], under iam-runtime/<name>.token.
+// Use a different name for each identity so tokens are not shared between them.
+// The name must be a single path element, names containing path separators or ".." are rejected.
+func NewUserFileCache(name string, codec Codec) (*FileCache, error) {
+	if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") || !filepath.IsLocal(name) {
+		return nil, fmt.Errorf("%w: invalid cache name: %q", iamruntime.ErrTokenCacheFailed, name)
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", iamruntime.ErrTokenCacheFailed, err)
+	}
+
+	return NewFileCache(filepath.Join(dir, "iam-runtime", name+".token"), codec), nil
+}
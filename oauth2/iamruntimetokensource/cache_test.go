@@ -0,0 +1,155 @@
+package iamruntimetokensource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	josejwt "github.com/go-jose/go-jose/v4/jwt"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+	"github.com/metal-toolbox/iam-runtime-contrib/internal/testauth"
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestFileCache(t *testing.T) {
+	ctx := context.Background()
+
+	dir := filepath.Join(t.TempDir(), "cache")
+
+	cache := NewFileCache(filepath.Join(dir, "service.token"), nil)
+
+	token, err := cache.Load(ctx)
+	require.NoError(t, err, "expected missing file not to be an error")
+	assert.Nil(t, token, "expected no token")
+
+	stored := &oauth2.Token{
+		AccessToken: "access-token",
+		TokenType:   "Bearer",
+		Expiry:      time.Unix(1704067200, 0),
+	}
+
+	require.NoError(t, cache.Store(ctx, stored), "unexpected error storing token")
+
+	info, err := os.Stat(cache.Path())
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "unexpected file permissions")
+
+	info, err = os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm(), "unexpected directory permissions")
+
+	token, err = cache.Load(ctx)
+	require.NoError(t, err, "unexpected error loading token")
+	assert.Equal(t, stored.AccessToken, token.AccessToken, "unexpected access token")
+	assert.True(t, stored.Expiry.Equal(token.Expiry), "unexpected expiry")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "expected temporary files to be removed")
+
+	require.NoError(t, os.WriteFile(cache.Path(), []byte("not json"), 0o600))
+
+	_, err = cache.Load(ctx)
+	assert.ErrorIs(t, err, iamruntime.ErrTokenCacheFailed, "expected corrupt cache to fail")
+}
+
+func TestTokenSourceCache(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	ctx := context.Background()
+
+	testCases := []struct {
+		name        string
+		cached      *oauth2.Token
+		expectFetch bool
+	}{
+		{
+			"empty",
+			nil,
+			true,
+		},
+		{
+			"valid",
+			&oauth2.Token{AccessToken: "cached-token", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)},
+			false,
+		},
+		{
+			"expired",
+			&oauth2.Token{AccessToken: "cached-token", TokenType: "Bearer", Expiry: time.Now().Add(-time.Hour)},
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cache := NewFileCache(filepath.Join(t.TempDir(), "service.token"), nil)
+
+			if tc.cached != nil {
+				require.NoError(t, cache.Store(ctx, tc.cached))
+			}
+
+			runtime := new(mockruntime.MockRuntime)
+
+			fetched := authsrv.TSignSubject(t, "some subject", testauth.Expiry(josejwt.NewNumericDate(time.Now().Add(time.Hour))))
+
+			if tc.expectFetch {
+				runtime.Mock.On("GetAccessToken").Return(&identity.GetAccessTokenResponse{Token: fetched}, nil).Once()
+			}
+
+			tokenSource, err := NewTokenSource(ctx, runtime, WithCache(cache))
+			require.NoError(t, err, "unexpected error creating new token source")
+
+			token, err := tokenSource.Token()
+			require.NoError(t, err, "unexpected error getting token")
+
+			runtime.Mock.AssertExpectations(t)
+
+			if !tc.expectFetch {
+				assert.Equal(t, tc.cached.AccessToken, token.AccessToken, "expected cached token")
+
+				return
+			}
+
+			assert.Equal(t, fetched, token.AccessToken, "expected fetched token")
+
+			stored, err := cache.Load(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, fetched, stored.AccessToken, "expected fetched token to be cached")
+		})
+	}
+}
+
+func ExampleNewUserFileCache() {
+	runtime, _ := iamruntime.NewClient("unix:///tmp/runtime.sock")
+
+	ctx := context.TODO()
+
+	cache, _ := NewUserFileCache("inventory-cli", nil)
+
+	iamtoken, _ := NewTokenSource(ctx, runtime, WithCache(cache))
+
+	httpClient := oauth2.NewClient(ctx, iamtoken)
+
+	_ = httpClient
+}
+
+func TestNewUserFileCacheInvalidName(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	for _, name := range []string{"", "../service", "nested/service", `nested\service`, "/etc/service", "..", "service..token"} {
+		_, err := NewUserFileCache(name, nil)
+		assert.ErrorIs(t, err, iamruntime.ErrTokenCacheFailed, "expected name %q to be rejected", name)
+	}
+
+	cache, err := NewUserFileCache("service", nil)
+	require.NoError(t, err, "unexpected error creating cache")
+	assert.Equal(t, "service.token", filepath.Base(cache.Path()), "unexpected cache file name")
+}
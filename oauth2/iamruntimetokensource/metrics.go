@@ -0,0 +1,155 @@
+package iamruntimetokensource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+const meterName = "github.com/metal-toolbox/iam-runtime-contrib/oauth2/iamruntimetokensource"
+
+// RefreshEvent describes a new token fetched from the runtime.
+type RefreshEvent struct {
+	// Subject is the subject of the new token.
+	Subject string
+
+	// Expiry is when the new token expires.
+	// A zero time is set if the token does not expire.
+	Expiry time.Time
+
+	// Background is true if the token was fetched by a background refresh, see [WithRefreshAhead].
+	Background bool
+}
+
+// WithMeterProvider sets the meter provider used to record token metrics.
+//
+// The following metrics are recorded:
+//   - iamruntime.tokensource.fetches: the number of access token requests to the runtime.
+//   - iamruntime.tokensource.fetch.failures: the number of access token requests which failed.
+//   - iamruntime.tokensource.fetch.duration: the duration of access token requests in seconds.
+//   - iamruntime.tokensource.token.remaining: the remaining lifetime of the current token in seconds.
+//
+// The remaining lifetime is observed until the token source is closed, so close the source once it is no longer used,
+// see [TokenSource.Close].
+// Default is nil, no metrics are recorded.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(s *TokenSource) {
+		s.meterProvider = provider
+	}
+}
+
+// WithOnRefresh sets a function called each time a new token is fetched from the runtime,
+// such as to log identity rotations. Tokens loaded from a cache do not call the function.
+// The function is called without holding any locks, so it may call Token.
+// Default is nil, no function is called.
+func WithOnRefresh(fn func(RefreshEvent)) Option {
+	return func(s *TokenSource) {
+		s.onRefresh = fn
+	}
+}
+
+// tokenMetrics are the instruments recording token source metrics.
+// The zero value records nothing.
+type tokenMetrics struct {
+	fetches      metric.Int64Counter
+	failures     metric.Int64Counter
+	duration     metric.Float64Histogram
+	registration metric.Registration
+}
+
+// init creates the instruments using the provider.
+// The remaining lifetime is observed with the provided function, which returns false if there is no current token.
+func (m *tokenMetrics) init(provider metric.MeterProvider, remaining func() (time.Duration, bool)) error {
+	meter := provider.Meter(meterName)
+
+	var errs []error
+
+	fetches, err := meter.Int64Counter("iamruntime.tokensource.fetches",
+		metric.WithDescription("The number of access token requests to the runtime."),
+		metric.WithUnit("{request}"),
+	)
+	errs = append(errs, err)
+
+	failures, err := meter.Int64Counter("iamruntime.tokensource.fetch.failures",
+		metric.WithDescription("The number of access token requests to the runtime which failed."),
+		metric.WithUnit("{request}"),
+	)
+	errs = append(errs, err)
+
+	duration, err := meter.Float64Histogram("iamruntime.tokensource.fetch.duration",
+		metric.WithDescription("The duration of access token requests to the runtime."),
+		metric.WithUnit("s"),
+	)
+	errs = append(errs, err)
+
+	lifetime, err := meter.Float64ObservableGauge("iamruntime.tokensource.token.remaining",
+		metric.WithDescription("The remaining lifetime of the current access token."),
+		metric.WithUnit("s"),
+	)
+	errs = append(errs, err)
+
+	if err = errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w: failed to create metrics: %w", iamruntime.ErrTokenSourceOptionInvalid, err)
+	}
+
+	registration, err := meter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+		if value, ok := remaining(); ok {
+			observer.ObserveFloat64(lifetime, value.Seconds())
+		}
+
+		return nil
+	}, lifetime)
+	if err != nil {
+		return fmt.Errorf("%w: failed to register metrics: %w", iamruntime.ErrTokenSourceOptionInvalid, err)
+	}
+
+	m.fetches = fetches
+	m.failures = failures
+	m.duration = duration
+	m.registration = registration
+
+	return nil
+}
+
+// recordFetch records an access token request.
+func (m *tokenMetrics) recordFetch(ctx context.Context, duration time.Duration, err error) {
+	if m.fetches == nil {
+		return
+	}
+
+	// Record even if the request context was canceled.
+	ctx = context.WithoutCancel(ctx)
+
+	m.fetches.Add(ctx, 1)
+	m.duration.Record(ctx, duration.Seconds())
+
+	if err != nil {
+		m.failures.Add(ctx, 1)
+	}
+}
+
+// unregister stops observing the remaining lifetime.
+func (m *tokenMetrics) unregister() {
+	if m.registration != nil {
+		m.registration.Unregister() //nolint:errcheck // unregistering only fails if already unregistered.
+
+		m.registration = nil
+	}
+}
+
+// remainingLifetime returns the remaining lifetime of the current token.
+// False is returned if there is no current token or it does not expire.
+func (s *TokenSource) remainingLifetime() (time.Duration, bool) {
+	expiry := s.expiry.Load()
+
+	if expiry == nil || expiry.IsZero() {
+		return 0, false
+	}
+
+	return expiry.Sub(s.now()), true
+}
@@ -0,0 +1,225 @@
+package iamruntimetokensource
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	josejwt "github.com/go-jose/go-jose/v4/jwt"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+	"github.com/metal-toolbox/iam-runtime-contrib/internal/testauth"
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestTokenMetrics(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	ctx := context.Background()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiry := now.Add(time.Hour)
+
+	runtime := new(mockruntime.MockRuntime)
+
+	runtime.Mock.On("GetAccessToken").Return((*identity.GetAccessTokenResponse)(nil), grpc.ErrServerStopped).Once()
+	runtime.Mock.On("GetAccessToken").Return(&identity.GetAccessTokenResponse{
+		Token: authsrv.TSignSubject(t, "idntusr-service", testauth.Expiry(josejwt.NewNumericDate(expiry))),
+	}, nil).Once()
+
+	reader := sdkmetric.NewManualReader()
+
+	var events []RefreshEvent
+
+	tokenSource, err := NewTokenSource(ctx, runtime,
+		WithClock(func() time.Time { return now }),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		WithOnRefresh(func(event RefreshEvent) { events = append(events, event) }),
+	)
+	require.NoError(t, err, "unexpected error creating new token source")
+
+	_, err = tokenSource.Token()
+	require.ErrorIs(t, err, iamruntime.ErrIdentityTokenRequestFailed, "expected first fetch to fail")
+
+	_, err = tokenSource.Token()
+	require.NoError(t, err, "unexpected error getting token")
+
+	// Valid tokens are not fetched again.
+	_, err = tokenSource.Token()
+	require.NoError(t, err, "unexpected error getting token")
+
+	now = now.Add(15 * time.Minute)
+
+	require.Len(t, events, 1, "expected a single refresh event")
+	assert.Equal(t, "idntusr-service", events[0].Subject, "unexpected refresh subject")
+	assert.True(t, expiry.Equal(events[0].Expiry), "unexpected refresh expiry")
+	assert.False(t, events[0].Background, "expected refresh not to be in the background")
+
+	var data metricdata.ResourceMetrics
+
+	require.NoError(t, reader.Collect(ctx, &data), "unexpected error collecting metrics")
+
+	metrics := make(map[string]metricdata.Aggregation)
+
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+
+	sum := func(name string) int64 {
+		data, ok := metrics[name].(metricdata.Sum[int64])
+		require.True(t, ok, "expected %s to be recorded", name)
+
+		var total int64
+
+		for _, point := range data.DataPoints {
+			total += point.Value
+		}
+
+		return total
+	}
+
+	assert.Equal(t, int64(2), sum("iamruntime.tokensource.fetches"), "unexpected fetches")
+	assert.Equal(t, int64(1), sum("iamruntime.tokensource.fetch.failures"), "unexpected failures")
+
+	duration, ok := metrics["iamruntime.tokensource.fetch.duration"].(metricdata.Histogram[float64])
+	require.True(t, ok, "expected fetch duration to be recorded")
+	require.Len(t, duration.DataPoints, 1)
+	assert.Equal(t, uint64(2), duration.DataPoints[0].Count, "unexpected fetch duration count")
+
+	remaining, ok := metrics["iamruntime.tokensource.token.remaining"].(metricdata.Gauge[float64])
+	require.True(t, ok, "expected remaining lifetime to be recorded")
+	require.Len(t, remaining.DataPoints, 1)
+	assert.InDelta(t, (45 * time.Minute).Seconds(), remaining.DataPoints[0].Value, 1, "unexpected remaining lifetime")
+
+	tokenSource.Close()
+
+	data = metricdata.ResourceMetrics{}
+
+	require.NoError(t, reader.Collect(ctx, &data), "unexpected error collecting metrics")
+
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if gauge, ok := m.Data.(metricdata.Gauge[float64]); ok {
+				assert.Empty(t, gauge.DataPoints, "expected remaining lifetime to stop being observed after close")
+			}
+		}
+	}
+
+	runtime.Mock.AssertExpectations(t)
+}
+
+func TestTokenMetricsDefaultProvider(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	ctx := context.Background()
+
+	reader := sdkmetric.NewManualReader()
+
+	global := otel.GetMeterProvider()
+
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	t.Cleanup(func() { otel.SetMeterProvider(global) })
+
+	runtime := new(mockruntime.MockRuntime)
+
+	runtime.Mock.On("GetAccessToken").Return(&identity.GetAccessTokenResponse{
+		Token: authsrv.TSignSubject(t, "idntusr-service"),
+	}, nil).Once()
+
+	tokenSource, err := NewTokenSource(ctx, runtime)
+	require.NoError(t, err, "unexpected error creating new token source")
+
+	_, err = tokenSource.Token()
+	require.NoError(t, err, "unexpected error getting token")
+
+	var data metricdata.ResourceMetrics
+
+	require.NoError(t, reader.Collect(ctx, &data), "unexpected error collecting metrics")
+
+	assert.Empty(t, data.ScopeMetrics, "expected no metrics without a meter provider")
+
+	runtime.Mock.AssertExpectations(t)
+}
+
+// stalledIdentityClient signals when an access token is requested and blocks the request until released.
+type stalledIdentityClient struct {
+	requested chan struct{}
+	release   chan struct{}
+}
+
+func (c stalledIdentityClient) GetAccessToken(_ context.Context, _ *identity.GetAccessTokenRequest, _ ...grpc.CallOption) (*identity.GetAccessTokenResponse, error) {
+	close(c.requested)
+
+	<-c.release
+
+	return nil, grpc.ErrServerStopped
+}
+
+func TestTokenMetricsDuringFetch(t *testing.T) {
+	ctx := context.Background()
+
+	client := stalledIdentityClient{
+		requested: make(chan struct{}),
+		release:   make(chan struct{}),
+	}
+
+	reader := sdkmetric.NewManualReader()
+
+	tokenSource, err := NewTokenSource(ctx, client, WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	require.NoError(t, err, "unexpected error creating new token source")
+
+	t.Cleanup(tokenSource.Close)
+
+	fetched := make(chan error)
+
+	go func() {
+		_, err := tokenSource.Token()
+
+		fetched <- err
+	}()
+
+	<-client.requested
+
+	collected := make(chan error, 1)
+
+	go func() {
+		collected <- reader.Collect(ctx, &metricdata.ResourceMetrics{})
+	}()
+
+	select {
+	case err = <-collected:
+		assert.NoError(t, err, "unexpected error collecting metrics")
+	case <-time.After(time.Second):
+		t.Error("expected metrics to be collected while a token is being fetched")
+	}
+
+	close(client.release)
+
+	assert.ErrorIs(t, <-fetched, iamruntime.ErrIdentityTokenRequestFailed, "expected fetch to fail")
+}
+
+func ExampleWithOnRefresh() {
+	runtime, _ := iamruntime.NewClient("unix:///tmp/runtime.sock")
+
+	iamtoken, _ := NewTokenSource(context.TODO(), runtime,
+		WithRefreshAhead(0.8, time.Minute),
+		WithOnRefresh(func(event RefreshEvent) {
+			fmt.Println("refreshed access token for", event.Subject, "expiring at", event.Expiry)
+		}),
+	)
+
+	defer iamtoken.Close()
+}
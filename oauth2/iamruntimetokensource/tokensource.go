@@ -4,16 +4,81 @@ package iamruntimetokensource
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/identity"
+	"go.opentelemetry.io/otel/metric"
 	"golang.org/x/oauth2"
 
 	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
 )
 
+const (
+	defaultExpiryLeeway = 10 * time.Second
+
+	// minRefreshDelay is the minimum delay between background refreshes.
+	minRefreshDelay = time.Second
+)
+
+// Option configures a [TokenSource].
+type Option func(*TokenSource)
+
+// WithRefreshAhead enables refreshing the token in the background once the fraction of its lifetime has elapsed.
+// The refresh is scheduled up to jitter earlier, so multiple instances do not refresh at the same time,
+// but no earlier than one second and no later than the token is treated as expired, see [WithExpiryLeeway].
+// The current token continues to be returned while the refresh runs.
+// If a refresh fails, it is retried once half of the remaining lifetime has elapsed.
+// Tokens expiring too soon to be refreshed ahead are refreshed by the next call to Token once expired.
+//
+// The fraction must be greater than 0 and less than 1.
+// Default is disabled, tokens are only refreshed once expired.
+func WithRefreshAhead(fraction float64, jitter time.Duration) Option {
+	return func(s *TokenSource) {
+		s.refreshFraction = fraction
+		s.refreshJitter = jitter
+	}
+}
+
+// WithFetchTimeout sets the timeout for each access token request to the runtime.
+// Default is no timeout, requests are bound only by the provided context.
+func WithFetchTimeout(timeout time.Duration) Option {
+	return func(s *TokenSource) {
+		s.fetchTimeout = timeout
+	}
+}
+
+// WithExpiryLeeway sets how long before its expiry a token is treated as expired,
+// allowing for clock skew between this service and the services receiving the token.
+// Default is 10 seconds.
+func WithExpiryLeeway(leeway time.Duration) Option {
+	return func(s *TokenSource) {
+		s.expiryLeeway = leeway
+	}
+}
+
+// WithClock sets the function used to get the current time.
+// Default is [time.Now].
+func WithClock(now func() time.Time) Option {
+	return func(s *TokenSource) {
+		s.now = now
+	}
+}
+
+// WithCache sets a cache used to share tokens between processes, such as repeated runs of a CLI tool.
+// The cached token is loaded on the first call to Token and used if it has not expired.
+// New tokens are stored in the cache once fetched. Errors loading or storing tokens are ignored.
+// See [NewUserFileCache] for a file backed cache.
+// Default is nil, tokens are only kept in memory.
+func WithCache(cache Cache) Option {
+	return func(s *TokenSource) {
+		s.cache = cache
+	}
+}
+
 // TokenSource handles token exchanges by taking an upstream token
 // and exchanging it with an token issuer and returning the new token.
 type TokenSource struct {
@@ -21,31 +86,144 @@ type TokenSource struct {
 	runtime identity.IdentityClient
 	token   *oauth2.Token
 	mu      sync.Mutex
+
+	fetchTimeout time.Duration
+	expiryLeeway time.Duration
+	now          func() time.Time
+	cache        Cache
+	cacheLoaded  bool
+	metrics      tokenMetrics
+	onRefresh    func(RefreshEvent)
+
+	// expiry is the expiry of the current token, read by the remaining lifetime metric without holding the lock,
+	// which is held while fetching tokens.
+	expiry atomic.Pointer[time.Time]
+
+	meterProvider metric.MeterProvider
+
+	refreshFraction float64
+	refreshJitter   time.Duration
+	refreshTimer    *time.Timer
+	nextRefresh     time.Time
+	lastErr         error
+	closed          bool
 }
 
 // Token requests an access token from the configured runtime.
 // Tokens are reused as long as they are valid.
+//
+// The request uses the values of the context provided to [NewTokenSource], but is not canceled with it.
+// Use [TokenSource.TokenContext] to bound the request with a context.
 func (s *TokenSource) Token() (*oauth2.Token, error) {
+	return s.TokenContext(context.WithoutCancel(s.ctx))
+}
+
+// TokenContext is the same as [TokenSource.Token] except the provided context is used to request a new token.
+func (s *TokenSource) TokenContext(ctx context.Context) (*oauth2.Token, error) {
+	token, event, err := s.retrieve(ctx)
+
+	if event != nil {
+		s.notifyRefresh(*event)
+	}
+
+	return token, err
+}
+
+// retrieve returns the current token, fetching a new token if it is no longer valid.
+// If a new token was fetched, the refresh event is returned.
+func (s *TokenSource) retrieve(ctx context.Context) (*oauth2.Token, *RefreshEvent, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.token.Valid() {
-		return s.token, nil
+	if s.valid(s.token) {
+		return s.token, nil, nil
+	}
+
+	if token := s.loadCache(ctx); token != nil {
+		s.setToken(token)
+
+		return s.token, nil, nil
 	}
 
-	resp, err := s.runtime.GetAccessToken(s.ctx, &identity.GetAccessTokenRequest{})
+	token, subject, err := s.fetch(ctx)
+
+	s.lastErr = err
+
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", iamruntime.ErrIdentityTokenRequestFailed, err)
+		return nil, nil, err
+	}
+
+	s.setToken(token)
+	s.storeCache(ctx, token)
+
+	return s.token, &RefreshEvent{Subject: subject, Expiry: token.Expiry}, nil
+}
+
+// loadCache returns the cached token the first time it is called, if the token is still valid.
+// The caller must hold the lock.
+func (s *TokenSource) loadCache(ctx context.Context) *oauth2.Token {
+	if s.cache == nil || s.cacheLoaded {
+		return nil
+	}
+
+	s.cacheLoaded = true
+
+	token, err := s.cache.Load(ctx)
+	if err != nil || !s.valid(token) {
+		return nil
+	}
+
+	return token
+}
+
+// storeCache stores the token in the cache, if one is configured.
+func (s *TokenSource) storeCache(ctx context.Context, token *oauth2.Token) {
+	if s.cache != nil {
+		_ = s.cache.Store(ctx, token)
+	}
+}
+
+// valid returns true if the token is set and does not expire within the expiry leeway.
+func (s *TokenSource) valid(token *oauth2.Token) bool {
+	if token == nil || token.AccessToken == "" {
+		return false
+	}
+
+	return token.Expiry.IsZero() || s.now().Add(s.expiryLeeway).Before(token.Expiry)
+}
+
+// fetch requests a new access token from the runtime, returning the token and its subject.
+func (s *TokenSource) fetch(ctx context.Context) (*oauth2.Token, string, error) {
+	start := time.Now()
+
+	token, subject, err := s.requestToken(ctx)
+
+	s.metrics.recordFetch(ctx, time.Since(start), err)
+
+	return token, subject, err
+}
+
+func (s *TokenSource) requestToken(ctx context.Context) (*oauth2.Token, string, error) {
+	if s.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, s.fetchTimeout)
+		defer cancel()
+	}
+
+	resp, err := s.runtime.GetAccessToken(ctx, &identity.GetAccessTokenRequest{})
+	if err != nil {
+		return nil, "", iamruntime.NewRuntimeCallError(iamruntime.ErrIdentityTokenRequestFailed, "GetAccessToken", err)
 	}
 
 	token, _, err := jwt.NewParser().ParseUnverified(resp.Token, jwt.MapClaims{})
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", iamruntime.ErrAccessTokenInvalid, err)
+		return nil, "", fmt.Errorf("%w: %w", iamruntime.ErrAccessTokenInvalid, err)
 	}
 
 	expiry, err := token.Claims.GetExpirationTime()
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", iamruntime.ErrAccessTokenInvalid, err)
+		return nil, "", fmt.Errorf("%w: %w", iamruntime.ErrAccessTokenInvalid, err)
 	}
 
 	var expiryTime time.Time
@@ -54,19 +232,201 @@ func (s *TokenSource) Token() (*oauth2.Token, error) {
 		expiryTime = expiry.Time
 	}
 
-	s.token = &oauth2.Token{
+	subject, err := token.Claims.GetSubject()
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", iamruntime.ErrAccessTokenInvalid, err)
+	}
+
+	return &oauth2.Token{
 		AccessToken: resp.Token,
 		TokenType:   "Bearer",
 		Expiry:      expiryTime,
+	}, subject, nil
+}
+
+// setToken stores the token and schedules the next background refresh.
+// The caller must hold the lock.
+func (s *TokenSource) setToken(token *oauth2.Token) {
+	s.token = token
+
+	s.expiry.Store(&token.Expiry)
+
+	if s.refreshFraction <= 0 || token.Expiry.IsZero() {
+		s.schedule(time.Time{})
+
+		return
+	}
+
+	now := s.now()
+
+	lifetime := token.Expiry.Sub(now)
+
+	// Refresh before the token is treated as expired, otherwise callers block on fetching a new token.
+	valid := lifetime - s.expiryLeeway
+
+	if valid < minRefreshDelay {
+		s.schedule(time.Time{})
+
+		return
+	}
+
+	delay := time.Duration(float64(lifetime) * s.refreshFraction)
+
+	if s.refreshJitter > 0 {
+		delay -= rand.N(s.refreshJitter) //nolint:gosec // jitter does not need to be cryptographically secure.
 	}
 
-	return s.token, nil
+	s.schedule(now.Add(min(max(delay, minRefreshDelay), valid)))
+}
+
+// schedule sets the time of the next background refresh, replacing any scheduled refresh.
+// A zero time cancels the scheduled refresh.
+// The caller must hold the lock.
+func (s *TokenSource) schedule(at time.Time) {
+	if s.refreshTimer != nil {
+		s.refreshTimer.Stop()
+		s.refreshTimer = nil
+	}
+
+	s.nextRefresh = at
+
+	if at.IsZero() || s.closed {
+		s.nextRefresh = time.Time{}
+
+		return
+	}
+
+	s.refreshTimer = time.AfterFunc(at.Sub(s.now()), s.refresh)
+}
+
+// refresh requests a new token in the background, keeping the current token until the new token is received.
+func (s *TokenSource) refresh() {
+	if s.ctx.Err() != nil {
+		s.Close()
+
+		return
+	}
+
+	token, subject, err := s.fetch(s.ctx)
+
+	s.update(token, err)
+
+	if err == nil {
+		s.notifyRefresh(RefreshEvent{
+			Subject:    subject,
+			Expiry:     token.Expiry,
+			Background: true,
+		})
+	}
+}
+
+// update stores the result of a background refresh.
+func (s *TokenSource) update(token *oauth2.Token, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastErr = err
+
+	if err == nil {
+		s.setToken(token)
+		s.storeCache(s.ctx, token)
+
+		return
+	}
+
+	// Retry while the current token is still valid, otherwise the next call to Token fetches a new token.
+	if s.token != nil && !s.token.Expiry.IsZero() {
+		now := s.now()
+
+		if valid := s.token.Expiry.Sub(now) - s.expiryLeeway; valid >= minRefreshDelay {
+			s.schedule(now.Add(max(valid/2, minRefreshDelay)))
+
+			return
+		}
+	}
+
+	s.schedule(time.Time{})
+}
+
+// notifyRefresh calls the refresh callback, if one is configured.
+func (s *TokenSource) notifyRefresh(event RefreshEvent) {
+	if s.onRefresh != nil {
+		s.onRefresh(event)
+	}
+}
+
+// Invalidate discards the token if it is the current token, so the next call to Token fetches a new token.
+// Tokens other than the current token are ignored, so concurrent requests rejecting the same token
+// result in a single new token.
+func (s *TokenSource) Invalidate(token *oauth2.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token == nil || s.token == nil || s.token.AccessToken != token.AccessToken {
+		return
+	}
+
+	s.token = nil
+
+	s.expiry.Store(nil)
+
+	s.schedule(time.Time{})
+}
+
+// LastRefreshError returns the error from the most recent attempt to fetch a token.
+// If the most recent attempt succeeded, nil is returned.
+func (s *TokenSource) LastRefreshError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastErr
+}
+
+// NextRefresh returns when the token will next be refreshed in the background.
+// A zero time is returned if no refresh is scheduled.
+func (s *TokenSource) NextRefresh() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.nextRefresh
+}
+
+// Close stops any background refresh and unregisters the remaining lifetime metric.
+// Token may still be called, but tokens are no longer refreshed ahead of their expiry.
+func (s *TokenSource) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	s.schedule(time.Time{})
+
+	s.metrics.unregister()
 }
 
 // NewTokenSource creates a new TokenSource using the provided upstream token source and runtime to generate new tokens.
-func NewTokenSource(ctx context.Context, runtime identity.IdentityClient) (*TokenSource, error) {
-	return &TokenSource{
-		ctx:     ctx,
-		runtime: runtime,
-	}, nil
+// Background refreshes stop once the provided context is canceled.
+func NewTokenSource(ctx context.Context, runtime identity.IdentityClient, opts ...Option) (*TokenSource, error) {
+	source := &TokenSource{
+		ctx:          ctx,
+		runtime:      runtime,
+		expiryLeeway: defaultExpiryLeeway,
+		now:          time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(source)
+	}
+
+	if source.refreshFraction < 0 || source.refreshFraction >= 1 {
+		return nil, fmt.Errorf("%w: refresh ahead fraction must be greater than 0 and less than 1: %v", iamruntime.ErrTokenSourceOptionInvalid, source.refreshFraction)
+	}
+
+	if source.meterProvider != nil {
+		if err := source.metrics.init(source.meterProvider, source.remainingLifetime); err != nil {
+			return nil, err
+		}
+	}
+
+	return source, nil
 }
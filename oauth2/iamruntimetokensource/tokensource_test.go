@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	josejwt "github.com/go-jose/go-jose/v4/jwt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/identity"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
 	"google.golang.org/grpc"
@@ -80,6 +84,271 @@ func TestToken(t *testing.T) {
 	}
 }
 
+func TestTokenRefreshAhead(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	ctx := context.Background()
+
+	expiry := time.Now().Add(time.Hour)
+
+	runtime := new(mockruntime.MockRuntime)
+
+	runtime.Mock.On("GetAccessToken").Return(&identity.GetAccessTokenResponse{
+		Token: authsrv.TSignSubject(t, "first subject", testauth.Expiry(josejwt.NewNumericDate(expiry))),
+	}, nil).Once()
+
+	tokenSource, err := NewTokenSource(ctx, runtime, WithRefreshAhead(0.5, time.Minute))
+	require.NoError(t, err, "unexpected error creating new token source")
+
+	t.Cleanup(tokenSource.Close)
+
+	assert.True(t, tokenSource.NextRefresh().IsZero(), "expected no refresh to be scheduled before the first token")
+
+	first, err := tokenSource.Token()
+	require.NoError(t, err, "unexpected error getting token")
+
+	halfLife := time.Until(expiry) / 2
+
+	assert.WithinRange(t, tokenSource.NextRefresh(), time.Now().Add(halfLife-2*time.Minute), time.Now().Add(halfLife), "unexpected next refresh")
+
+	// A failed refresh keeps the current token and is retried.
+	runtime.Mock.On("GetAccessToken").Return((*identity.GetAccessTokenResponse)(nil), grpc.ErrServerStopped).Once()
+
+	tokenSource.refresh()
+
+	assert.ErrorIs(t, tokenSource.LastRefreshError(), iamruntime.ErrIdentityTokenRequestFailed, "expected refresh error")
+	assert.WithinDuration(t, time.Now().Add((time.Until(expiry)-defaultExpiryLeeway)/2), tokenSource.NextRefresh(), time.Second, "expected refresh to be retried")
+
+	token, err := tokenSource.Token()
+	require.NoError(t, err, "expected current token to be returned after failed refresh")
+	assert.Equal(t, first.AccessToken, token.AccessToken, "expected current token to be kept")
+
+	runtime.Mock.On("GetAccessToken").Return(&identity.GetAccessTokenResponse{
+		Token: authsrv.TSignSubject(t, "second subject", testauth.Expiry(josejwt.NewNumericDate(expiry))),
+	}, nil).Once()
+
+	tokenSource.refresh()
+
+	assert.NoError(t, tokenSource.LastRefreshError(), "expected refresh error to be cleared")
+
+	token, err = tokenSource.Token()
+	require.NoError(t, err, "unexpected error getting token")
+	assert.NotEqual(t, first.AccessToken, token.AccessToken, "expected refreshed token to be returned")
+
+	tokenSource.Close()
+
+	assert.True(t, tokenSource.NextRefresh().IsZero(), "expected closing to cancel the scheduled refresh")
+
+	runtime.Mock.AssertExpectations(t)
+}
+
+func TestTokenRefreshAheadBackground(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	runtime := new(mockruntime.MockRuntime)
+
+	var calls atomic.Int32
+
+	runtime.Mock.On("GetAccessToken").Return(&identity.GetAccessTokenResponse{
+		Token: authsrv.TSignSubject(t, "some subject", testauth.Expiry(josejwt.NewNumericDate(time.Now().Add(3*time.Second)))),
+	}, nil).Run(func(mock.Arguments) { calls.Add(1) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tokenSource, err := NewTokenSource(ctx, runtime, WithRefreshAhead(0.1, 0), WithExpiryLeeway(0))
+	require.NoError(t, err, "unexpected error creating new token source")
+
+	_, err = tokenSource.Token()
+	require.NoError(t, err, "unexpected error getting token")
+
+	assert.Eventually(t, func() bool {
+		return calls.Load() > 1
+	}, 3*time.Second, 10*time.Millisecond, "expected token to be refreshed in the background")
+
+	cancel()
+
+	assert.Eventually(t, func() bool {
+		return tokenSource.NextRefresh().IsZero()
+	}, 2*time.Second, 10*time.Millisecond, "expected canceling the context to stop refreshing")
+}
+
+func TestTokenRefreshAheadSchedule(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		expiry        time.Time
+		fraction      float64
+		jitter        time.Duration
+		expectRefresh time.Time
+	}{
+		{
+			"fraction of lifetime",
+			now.Add(time.Hour),
+			0.5,
+			0,
+			now.Add(30 * time.Minute),
+		},
+		{
+			"jitter exceeds delay",
+			now.Add(time.Hour),
+			0.0001,
+			time.Hour,
+			now.Add(minRefreshDelay),
+		},
+		{
+			"delay exceeds expiry leeway",
+			now.Add(time.Minute),
+			0.9,
+			0,
+			now.Add(time.Minute - defaultExpiryLeeway),
+		},
+		{
+			"expires within expiry leeway",
+			now.Add(defaultExpiryLeeway),
+			0.5,
+			0,
+			time.Time{},
+		},
+		{
+			"expired",
+			now.Add(-time.Second),
+			0.5,
+			0,
+			time.Time{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := new(mockruntime.MockRuntime)
+
+			runtime.Mock.On("GetAccessToken").Return(&identity.GetAccessTokenResponse{
+				Token: authsrv.TSignSubject(t, "some subject", testauth.Expiry(josejwt.NewNumericDate(tc.expiry))),
+			}, nil).Once()
+
+			tokenSource, err := NewTokenSource(context.Background(), runtime,
+				WithRefreshAhead(tc.fraction, tc.jitter),
+				WithClock(func() time.Time { return now }),
+			)
+			require.NoError(t, err, "unexpected error creating new token source")
+
+			t.Cleanup(tokenSource.Close)
+
+			_, err = tokenSource.Token()
+			require.NoError(t, err, "unexpected error getting token")
+
+			assert.Equal(t, tc.expectRefresh, tokenSource.NextRefresh(), "unexpected next refresh")
+
+			runtime.Mock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewTokenSourceInvalidOption(t *testing.T) {
+	for _, fraction := range []float64{-0.5, 1, 1.5} {
+		_, err := NewTokenSource(context.Background(), new(mockruntime.MockRuntime), WithRefreshAhead(fraction, 0))
+		assert.ErrorIs(t, err, iamruntime.ErrTokenSourceOptionInvalid, "expected fraction %v to be rejected", fraction)
+	}
+}
+
+func TestTokenExpiryLeeway(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiry := now.Add(time.Hour)
+
+	runtime := new(mockruntime.MockRuntime)
+
+	runtime.Mock.On("GetAccessToken").Return(&identity.GetAccessTokenResponse{
+		Token: authsrv.TSignSubject(t, "some subject", testauth.Expiry(josejwt.NewNumericDate(expiry))),
+	}, nil).Twice()
+
+	tokenSource, err := NewTokenSource(context.Background(), runtime,
+		WithExpiryLeeway(5*time.Minute),
+		WithClock(func() time.Time { return now }),
+	)
+	require.NoError(t, err, "unexpected error creating new token source")
+
+	_, err = tokenSource.Token()
+	require.NoError(t, err, "unexpected error getting token")
+
+	now = expiry.Add(-6 * time.Minute)
+
+	_, err = tokenSource.Token()
+	require.NoError(t, err, "unexpected error getting token")
+
+	runtime.Mock.AssertNumberOfCalls(t, "GetAccessToken", 1)
+
+	now = expiry.Add(-4 * time.Minute)
+
+	_, err = tokenSource.Token()
+	require.NoError(t, err, "unexpected error getting token")
+
+	runtime.Mock.AssertExpectations(t)
+}
+
+// blockingIdentityClient blocks access token requests until the request context is done.
+type blockingIdentityClient struct{}
+
+func (blockingIdentityClient) GetAccessToken(ctx context.Context, _ *identity.GetAccessTokenRequest, _ ...grpc.CallOption) (*identity.GetAccessTokenResponse, error) {
+	<-ctx.Done()
+
+	return nil, ctx.Err()
+}
+
+func TestTokenContext(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	t.Run("fetch timeout", func(t *testing.T) {
+		tokenSource, err := NewTokenSource(context.Background(), blockingIdentityClient{}, WithFetchTimeout(10*time.Millisecond))
+		require.NoError(t, err, "unexpected error creating new token source")
+
+		_, err = tokenSource.Token()
+		assert.ErrorIs(t, err, iamruntime.ErrIdentityTokenRequestFailed, "expected request error")
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "expected request to time out")
+	})
+
+	t.Run("caller context", func(t *testing.T) {
+		tokenSource, err := NewTokenSource(context.Background(), blockingIdentityClient{})
+		require.NoError(t, err, "unexpected error creating new token source")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = tokenSource.TokenContext(ctx)
+		assert.ErrorIs(t, err, context.Canceled, "expected caller context to be used")
+	})
+
+	t.Run("source context canceled", func(t *testing.T) {
+		runtime := new(mockruntime.MockRuntime)
+
+		runtime.Mock.On("GetAccessToken").Return(&identity.GetAccessTokenResponse{
+			Token: authsrv.TSignSubject(t, "some subject"),
+		}, nil).Once()
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		tokenSource, err := NewTokenSource(ctx, runtime)
+		require.NoError(t, err, "unexpected error creating new token source")
+
+		cancel()
+
+		_, err = tokenSource.Token()
+		assert.NoError(t, err, "expected canceled source context not to break the source")
+
+		runtime.Mock.AssertExpectations(t)
+	})
+}
+
 func ExampleNewTokenSource() {
 	runtime, _ := iamruntime.NewClient("unix:///tmp/runtime.sock")
 
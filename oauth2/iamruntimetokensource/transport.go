@@ -0,0 +1,133 @@
+package iamruntimetokensource
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/identity"
+	"golang.org/x/oauth2"
+)
+
+// maxDrainBytes is the maximum number of bytes read from a rejected response so the connection may be reused.
+const maxDrainBytes = 4 << 10
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// Transport is an [http.RoundTripper] which authorizes requests with tokens from a [TokenSource].
+//
+// Unlike [oauth2.Transport], if the upstream responds with 401 Unauthorized, for example after a key rotation,
+// the token is invalidated and the request is sent once more with a new token.
+// Requests are only retried if their body can be replayed: requests without a body
+// and requests with [http.Request.GetBody] set, as done by [http.NewRequest] for common body types.
+type Transport struct {
+	// Source provides the tokens added to requests.
+	Source *TokenSource
+
+	// Base is the transport used to send requests.
+	// Default is [http.DefaultTransport].
+	Base http.RoundTripper
+}
+
+// RoundTrip authorizes and sends the request, retrying once with a new token if the response is 401 Unauthorized.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Source.TokenContext(req.Context())
+	if err != nil {
+		closeBody(req)
+
+		return nil, err
+	}
+
+	resp, err := t.base().RoundTrip(authorize(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !replayable(req) {
+		return resp, err
+	}
+
+	body := req.Body
+
+	if req.GetBody != nil {
+		if body, err = req.GetBody(); err != nil {
+			return resp, nil //nolint:nilerr // the original response is returned if the body cannot be replayed.
+		}
+	}
+
+	t.Source.Invalidate(token)
+
+	token, err = t.Source.TokenContext(req.Context())
+	if err != nil {
+		if body != nil {
+			body.Close()
+		}
+
+		return resp, nil //nolint:nilerr // the original response is returned if a new token cannot be fetched.
+	}
+
+	drain(resp)
+
+	retry := authorize(req, token)
+	retry.Body = body
+
+	return t.base().RoundTrip(retry)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+
+	return http.DefaultTransport
+}
+
+// authorize returns a copy of the request with the token set as the Authorization header.
+func authorize(req *http.Request, token *oauth2.Token) *http.Request {
+	authorized := req.Clone(req.Context())
+
+	token.SetAuthHeader(authorized)
+
+	return authorized
+}
+
+// replayable returns true if the request body can be sent again.
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// drain reads the remainder of a response body, up to a limit, and closes it.
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes)) //nolint:errcheck // the body is discarded.
+	resp.Body.Close()
+}
+
+// closeBody closes the request body, as required of a [http.RoundTripper] even when the request is not sent.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// NewHTTPClient returns an [http.Client] which authorizes requests with access tokens from the runtime.
+// See [Transport] for details on how rejected tokens are handled.
+//
+// If the context has an [oauth2.HTTPClient] value, its transport is used to send requests.
+//
+// Background refreshes stop once the provided context is canceled.
+// The token source used by the client is not returned, so to record metrics with [WithMeterProvider],
+// build a [Transport] with a source from [NewTokenSource] which can be closed instead.
+func NewHTTPClient(ctx context.Context, runtime identity.IdentityClient, opts ...Option) (*http.Client, error) {
+	source, err := NewTokenSource(ctx, runtime, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &Transport{
+		Source: source,
+	}
+
+	if client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && client != nil {
+		transport.Base = client.Transport
+	}
+
+	return &http.Client{
+		Transport: transport,
+	}, nil
+}
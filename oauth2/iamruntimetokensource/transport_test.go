@@ -0,0 +1,166 @@
+package iamruntimetokensource
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+	"github.com/metal-toolbox/iam-runtime-contrib/internal/testauth"
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func TestTransport(t *testing.T) {
+	authsrv := testauth.NewServer(t)
+	t.Cleanup(authsrv.Stop)
+
+	rejected := authsrv.TSignSubject(t, "rejected subject")
+	accepted := authsrv.TSignSubject(t, "accepted subject")
+
+	testCases := []struct {
+		name          string
+		method        string
+		body          func() io.Reader
+		rejectAll     bool
+		expectFetches int
+		expectStatus  int
+		expectBodies  []string
+	}{
+		{
+			"no body retried",
+			http.MethodGet,
+			nil,
+			false,
+			2,
+			http.StatusOK,
+			[]string{"", ""},
+		},
+		{
+			"replayable body retried",
+			http.MethodPost,
+			func() io.Reader { return strings.NewReader("request body") },
+			false,
+			2,
+			http.StatusOK,
+			[]string{"request body", "request body"},
+		},
+		{
+			"unreplayable body not retried",
+			http.MethodPost,
+			func() io.Reader { return io.MultiReader(strings.NewReader("request body")) },
+			false,
+			1,
+			http.StatusUnauthorized,
+			[]string{"request body"},
+		},
+		{
+			"retried once",
+			http.MethodGet,
+			nil,
+			true,
+			2,
+			http.StatusUnauthorized,
+			[]string{"", ""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				bodies []string
+			)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+
+				mu.Lock()
+				bodies = append(bodies, string(body))
+				mu.Unlock()
+
+				if tc.rejectAll || r.Header.Get("Authorization") != "Bearer "+accepted {
+					w.WriteHeader(http.StatusUnauthorized)
+
+					return
+				}
+
+				w.WriteHeader(http.StatusOK)
+			}))
+			t.Cleanup(server.Close)
+
+			runtime := new(mockruntime.MockRuntime)
+
+			runtime.Mock.On("GetAccessToken").Return(&identity.GetAccessTokenResponse{Token: rejected}, nil).Once()
+
+			if tc.expectFetches > 1 {
+				runtime.Mock.On("GetAccessToken").Return(&identity.GetAccessTokenResponse{Token: accepted}, nil).Once()
+			}
+
+			ctx := context.Background()
+
+			client, err := NewHTTPClient(ctx, runtime)
+			require.NoError(t, err, "unexpected error creating client")
+
+			var body io.Reader
+
+			if tc.body != nil {
+				body = tc.body()
+			}
+
+			req, err := http.NewRequestWithContext(ctx, tc.method, server.URL, body)
+			require.NoError(t, err)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err, "unexpected error sending request")
+
+			resp.Body.Close()
+
+			assert.Equal(t, tc.expectStatus, resp.StatusCode, "unexpected status code")
+			assert.Equal(t, tc.expectBodies, bodies, "unexpected request bodies received")
+
+			runtime.Mock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTransportTokenError(t *testing.T) {
+	runtime := new(mockruntime.MockRuntime)
+
+	runtime.Mock.On("GetAccessToken").Return((*identity.GetAccessTokenResponse)(nil), fmt.Errorf("runtime unavailable")).Once()
+
+	client, err := NewHTTPClient(context.Background(), runtime)
+	require.NoError(t, err, "unexpected error creating client")
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://127.0.0.1:0", nil)
+	require.NoError(t, err)
+
+	_, err = client.Do(req) //nolint:bodyclose // no response is returned.
+	assert.ErrorIs(t, err, iamruntime.ErrIdentityTokenRequestFailed, "expected token error")
+
+	runtime.Mock.AssertExpectations(t)
+}
+
+func ExampleNewHTTPClient() {
+	runtime, _ := iamruntime.NewClient("unix:///tmp/runtime.sock")
+
+	ctx := context.TODO()
+
+	httpClient, _ := NewHTTPClient(ctx, runtime)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://iam.example.com/resource/explten-abc123", nil)
+
+	resp, _ := httpClient.Do(req)
+
+	resp.Body.Close()
+
+	fmt.Println("Status Code:", resp.StatusCode)
+}
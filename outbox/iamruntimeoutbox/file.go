@@ -0,0 +1,286 @@
+package iamruntimeoutbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+var _ Store = (*FileStore)(nil)
+
+// fileRecord is a single line of the outbox file.
+// A record either puts an entry or deletes the entry with the key.
+type fileRecord struct {
+	Entry   *Entry `json:"entry,omitempty"`
+	Deleted string `json:"deleted,omitempty"`
+}
+
+// FileStore is a [Store] backed by an append-only JSON lines file.
+//
+// Every change is appended to the file and synced to disk before it is applied,
+// so entries survive process restarts. The file is replayed into memory when opened.
+// If the final line of the file is incomplete, such as after a crash during a write, it is discarded.
+// If appending a change fails, the file is truncated to remove any partially written line.
+//
+// The file grows with every change. Use [FileStore.Compact] to rewrite it with only the current entries.
+type FileStore struct {
+	path string
+
+	mu    sync.Mutex
+	file  *os.File
+	size  int64
+	index *MemoryStore
+}
+
+// Get returns the entry with the provided key.
+func (s *FileStore) Get(ctx context.Context, key string) (Entry, bool, error) {
+	return s.index.Get(ctx, key)
+}
+
+// Put appends the entry to the file and adds or replaces it in the store.
+func (s *FileStore) Put(ctx context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(fileRecord{Entry: &entry}); err != nil {
+		return err
+	}
+
+	return s.index.Put(ctx, entry)
+}
+
+// Delete appends a deletion to the file and removes the entry from the store.
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok, _ := s.index.Get(ctx, key); !ok {
+		return nil
+	}
+
+	if err := s.append(fileRecord{Deleted: key}); err != nil {
+		return err
+	}
+
+	return s.index.Delete(ctx, key)
+}
+
+// List returns all entries in the order they were first added.
+func (s *FileStore) List(ctx context.Context) ([]Entry, error) {
+	return s.index.List(ctx)
+}
+
+// Compact rewrites the file with only the current entries.
+// The file is replaced atomically, so a failed compaction leaves the previous file intact.
+func (s *FileStore) Compact(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("%w: %s: %w", iamruntime.ErrOutboxStoreFailed, s.path, os.ErrClosed)
+	}
+
+	entries, err := s.index.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("%w: %w", iamruntime.ErrOutboxStoreFailed, err)
+	}
+
+	defer os.Remove(tmp.Name()) //nolint:errcheck // temp file is renamed on success.
+
+	writer := bufio.NewWriter(tmp)
+
+	for i := range entries {
+		line, err := json.Marshal(fileRecord{Entry: &entries[i]})
+		if err != nil {
+			tmp.Close()
+
+			return fmt.Errorf("%w: %w", iamruntime.ErrOutboxStoreFailed, err)
+		}
+
+		writer.Write(append(line, '\n')) //nolint:errcheck // write errors are returned by Flush.
+	}
+
+	err = errors.Join(writer.Flush(), tmp.Chmod(0o600), tmp.Sync(), tmp.Close())
+	if err != nil {
+		return fmt.Errorf("%w: %w", iamruntime.ErrOutboxStoreFailed, err)
+	}
+
+	if err = os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("%w: %w", iamruntime.ErrOutboxStoreFailed, err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("%w: %w", iamruntime.ErrOutboxStoreFailed, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+
+		return fmt.Errorf("%w: %w", iamruntime.ErrOutboxStoreFailed, err)
+	}
+
+	s.file.Close()
+	s.file = file
+	s.size = info.Size()
+
+	return nil
+}
+
+// Close closes the file.
+// The store can no longer be changed once closed.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+
+	s.file = nil
+
+	return err
+}
+
+func (s *FileStore) append(record fileRecord) error {
+	if s.file == nil {
+		return fmt.Errorf("%w: %s: %w", iamruntime.ErrOutboxStoreFailed, s.path, os.ErrClosed)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("%w: %w", iamruntime.ErrOutboxStoreFailed, err)
+	}
+
+	line = append(line, '\n')
+
+	if _, err = s.file.Write(line); err != nil {
+		return s.rollback(err)
+	}
+
+	if err = s.file.Sync(); err != nil {
+		return s.rollback(err)
+	}
+
+	s.size += int64(len(line))
+
+	return nil
+}
+
+// rollback truncates the file to its size before a failed append, so the next append does not continue
+// a partially written line. If the file can not be truncated, it is closed to avoid writing after the partial line.
+func (s *FileStore) rollback(err error) error {
+	_, terr := s.file.Seek(s.size, io.SeekStart)
+	if terr == nil {
+		terr = s.file.Truncate(s.size)
+	}
+
+	if terr != nil {
+		s.file.Close()
+		s.file = nil
+
+		return fmt.Errorf("%w: %w", iamruntime.ErrOutboxStoreFailed, errors.Join(err, terr))
+	}
+
+	return fmt.Errorf("%w: %w", iamruntime.ErrOutboxStoreFailed, err)
+}
+
+// load replays the records of the file into the index, returning the size of the valid records.
+func (s *FileStore) load(ctx context.Context, file *os.File) (int64, error) {
+	reader := bufio.NewReader(file)
+
+	var offset int64
+
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+
+		if errors.Is(err, io.EOF) {
+			// An incomplete final line is the result of an interrupted write and is discarded.
+			return offset, nil
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("%w: %w", iamruntime.ErrOutboxStoreFailed, err)
+		}
+
+		offset += int64(len(line))
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var record fileRecord
+
+		if err = json.Unmarshal(line, &record); err != nil {
+			return 0, fmt.Errorf("%w: %s:%d: %w", iamruntime.ErrOutboxStoreFailed, s.path, lineNum, err)
+		}
+
+		switch {
+		case record.Entry != nil:
+			err = s.index.Put(ctx, *record.Entry)
+		case record.Deleted != "":
+			err = s.index.Delete(ctx, record.Deleted)
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// OpenFileStore opens or creates the outbox file at the provided path and loads its entries.
+// The file is created with 0600 permissions.
+func OpenFileStore(ctx context.Context, path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", iamruntime.ErrOutboxStoreFailed, err)
+	}
+
+	store := &FileStore{
+		path:  path,
+		index: NewMemoryStore(),
+	}
+
+	size, err := store.load(ctx, file)
+	if err != nil {
+		file.Close()
+
+		return nil, err
+	}
+
+	// Remove any incomplete final line so new records start on a new line.
+	if err = file.Truncate(size); err != nil {
+		file.Close()
+
+		return nil, fmt.Errorf("%w: %w", iamruntime.ErrOutboxStoreFailed, err)
+	}
+
+	if _, err = file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+
+		return nil, fmt.Errorf("%w: %w", iamruntime.ErrOutboxStoreFailed, err)
+	}
+
+	store.file = file
+	store.size = size
+
+	return store, nil
+}
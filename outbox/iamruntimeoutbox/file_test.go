@@ -0,0 +1,134 @@
+package iamruntimeoutbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "outbox")
+
+	store, err := OpenFileStore(ctx, path)
+	require.NoError(t, err, "unexpected error opening store")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "unexpected file permissions")
+
+	entry := func(key string, state State) Entry {
+		return Entry{
+			Key: key,
+			Write: iamruntime.RelationshipWrite{
+				Operation:     iamruntime.RelationshipCreate,
+				ResourceID:    "testten-abc123",
+				Relationships: []iamruntime.Relationship{{Relation: iamruntime.RelationParent, SubjectID: "testten-root123"}},
+			},
+			State:     state,
+			CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+	}
+
+	require.NoError(t, store.Put(ctx, entry("first", StatePending)))
+	require.NoError(t, store.Put(ctx, entry("second", StatePending)))
+	require.NoError(t, store.Put(ctx, entry("third", StatePending)))
+	require.NoError(t, store.Put(ctx, entry("first", StateDone)))
+	require.NoError(t, store.Delete(ctx, "second"))
+	require.NoError(t, store.Close())
+
+	// Simulate a crash during a write.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+
+	_, err = file.WriteString(`{"entry":{"key":"partial"`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	store, err = OpenFileStore(ctx, path)
+	require.NoError(t, err, "unexpected error reopening store")
+
+	t.Cleanup(func() { store.Close() })
+
+	entries, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{entry("first", StateDone), entry("third", StatePending)}, entries, "unexpected entries after reopening")
+
+	require.NoError(t, store.Put(ctx, entry("fourth", StatePending)), "expected write after incomplete line to succeed")
+
+	sizeBefore, err := os.Stat(path)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Compact(ctx))
+
+	sizeAfter, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Less(t, sizeAfter.Size(), sizeBefore.Size(), "expected compaction to shrink the file")
+
+	require.NoError(t, store.Put(ctx, entry("fifth", StatePending)), "expected write after compaction to succeed")
+	require.NoError(t, store.Close())
+
+	store, err = OpenFileStore(ctx, path)
+	require.NoError(t, err, "unexpected error reopening compacted store")
+
+	entries, err = store.List(ctx)
+	require.NoError(t, err)
+
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = e.Key
+	}
+
+	assert.Equal(t, []string{"first", "third", "fourth", "fifth"}, keys, "unexpected entries after compaction")
+}
+
+func TestFileStoreFailedAppend(t *testing.T) {
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "outbox")
+
+	store, err := OpenFileStore(ctx, path)
+	require.NoError(t, err, "unexpected error opening store")
+
+	require.NoError(t, store.Put(ctx, Entry{Key: "first", State: StatePending}))
+
+	// Simulate a write failing after a partial line was written, such as when the disk is full.
+	_, err = store.file.WriteString(`{"entry":{"key":"partial"`)
+	require.NoError(t, err)
+
+	err = store.rollback(syscall.ENOSPC)
+	assert.ErrorIs(t, err, iamruntime.ErrOutboxStoreFailed, "expected store error")
+	assert.ErrorIs(t, err, syscall.ENOSPC, "expected write error to be wrapped")
+
+	require.NoError(t, store.Put(ctx, Entry{Key: "second", State: StatePending}), "expected write after failed write to succeed")
+	require.NoError(t, store.Close())
+
+	store, err = OpenFileStore(ctx, path)
+	require.NoError(t, err, "unexpected error reopening store")
+
+	t.Cleanup(func() { store.Close() })
+
+	entries, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "unexpected entries after reopening")
+	assert.Equal(t, "first", entries[0].Key, "unexpected entry")
+	assert.Equal(t, "second", entries[1].Key, "unexpected entry")
+}
+
+func TestOpenFileStoreCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox")
+
+	require.NoError(t, os.WriteFile(path, []byte("not json\n{}\n"), 0o600))
+
+	_, err := OpenFileStore(context.Background(), path)
+	assert.ErrorIs(t, err, iamruntime.ErrOutboxStoreFailed, "expected corrupt file to fail")
+}
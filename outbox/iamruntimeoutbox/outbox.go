@@ -0,0 +1,375 @@
+// Package iamruntimeoutbox implements a durable outbox for iam-runtime relationship writes.
+//
+// Relationship writes are persisted to a [Store] and applied to the runtime in order,
+// retrying with backoff while the runtime is unavailable.
+package iamruntimeoutbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = 5 * time.Minute
+)
+
+// Option configures an [Outbox].
+type Option func(*Outbox)
+
+// WithBackoff sets the delay before retrying a failed write.
+// The delay starts at min and doubles with every failed attempt up to max.
+// Default is 1 second up to 5 minutes.
+func WithBackoff(minDelay, maxDelay time.Duration) Option {
+	return func(o *Outbox) {
+		o.minBackoff = minDelay
+		o.maxBackoff = maxDelay
+	}
+}
+
+// WithMaxAttempts sets the number of attempts after which a write is dead lettered.
+// Writes enqueued after a dead lettered write are applied without it, so setting a limit
+// gives up ordering for writes which fail for longer than the attempts allow.
+// If attempts is 0 or less, writes are retried until they succeed or fail permanently.
+// Default is 0, no limit.
+func WithMaxAttempts(attempts int) Option {
+	return func(o *Outbox) {
+		o.maxAttempts = attempts
+	}
+}
+
+// Outbox persists relationship writes and applies them to the runtime in the order they were enqueued.
+//
+// Each write has an idempotency key. Enqueuing a write with a key which is already in the store is ignored,
+// so retried requests do not apply the same write twice.
+// Creating relationships which already exist and deleting relationships which do not exist are treated as applied.
+//
+// Writes which fail with a retryable error block the writes enqueued after them until they succeed,
+// preserving the order of writes. Writes rejected by the runtime as invalid or not permitted,
+// and writes which exceed the maximum number of attempts if set, see [WithMaxAttempts],
+// are dead lettered and no longer block the outbox.
+type Outbox struct {
+	runtime     authorization.AuthorizationClient
+	store       Store
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	maxAttempts int
+	now         func() time.Time
+
+	enqueueMu sync.Mutex
+	replayMu  sync.Mutex
+	notify    chan struct{}
+}
+
+// Enqueue validates and persists the write for the provided idempotency key.
+// If an entry with the key already exists, the write is ignored.
+func (o *Outbox) Enqueue(ctx context.Context, key string, write iamruntime.RelationshipWrite) error {
+	if key == "" {
+		return fmt.Errorf("%w: empty idempotency key", iamruntime.ErrOutboxStoreFailed)
+	}
+
+	if err := write.Validate(); err != nil {
+		return err
+	}
+
+	o.enqueueMu.Lock()
+	defer o.enqueueMu.Unlock()
+
+	if _, ok, err := o.store.Get(ctx, key); err != nil || ok {
+		return err
+	}
+
+	now := o.now()
+
+	err := o.store.Put(ctx, Entry{
+		Key:         key,
+		Write:       write,
+		State:       StatePending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		NextAttempt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// EnqueueCreate enqueues the creation of the builder's relationships.
+func (o *Outbox) EnqueueCreate(ctx context.Context, key string, relationships *iamruntime.RelationshipBuilder) error {
+	return o.Enqueue(ctx, key, iamruntime.RelationshipWrite{
+		Operation:     iamruntime.RelationshipCreate,
+		ResourceID:    relationships.ResourceID(),
+		Relationships: relationships.Relationships(),
+	})
+}
+
+// EnqueueDelete enqueues the deletion of the builder's relationships.
+func (o *Outbox) EnqueueDelete(ctx context.Context, key string, relationships *iamruntime.RelationshipBuilder) error {
+	return o.Enqueue(ctx, key, iamruntime.RelationshipWrite{
+		Operation:     iamruntime.RelationshipDelete,
+		ResourceID:    relationships.ResourceID(),
+		Relationships: relationships.Relationships(),
+	})
+}
+
+// Replay applies pending writes in order until a write fails with a retryable error
+// or the next write is waiting for its backoff to elapse.
+// The error of the failed write is returned.
+func (o *Outbox) Replay(ctx context.Context) error {
+	_, err := o.replay(ctx)
+
+	return err
+}
+
+// replay applies pending writes and returns when the next pending write should be attempted.
+// A zero time is returned if there are no pending writes.
+func (o *Outbox) replay(ctx context.Context) (time.Time, error) {
+	o.replayMu.Lock()
+	defer o.replayMu.Unlock()
+
+	entries, err := o.store.List(ctx)
+	if err != nil {
+		return o.now().Add(o.minBackoff), err
+	}
+
+	runtimeCtx := iamruntime.SetContextRuntimeAny(ctx, o.runtime)
+
+	for _, entry := range entries {
+		if entry.State != StatePending {
+			continue
+		}
+
+		if entry.NextAttempt.After(o.now()) {
+			return entry.NextAttempt, nil
+		}
+
+		applyErr := o.apply(runtimeCtx, entry.Write)
+
+		now := o.now()
+
+		entry.Attempts++
+		entry.UpdatedAt = now
+
+		switch {
+		case applyErr == nil:
+			entry.State = StateDone
+			entry.LastError = ""
+		case permanent(applyErr) || (o.maxAttempts > 0 && entry.Attempts >= o.maxAttempts):
+			entry.State = StateDeadLettered
+			entry.LastError = applyErr.Error()
+		default:
+			entry.LastError = applyErr.Error()
+			entry.NextAttempt = now.Add(o.backoff(entry.Attempts))
+		}
+
+		if err = o.store.Put(ctx, entry); err != nil {
+			return now.Add(o.minBackoff), err
+		}
+
+		if entry.State == StatePending {
+			return entry.NextAttempt, applyErr
+		}
+	}
+
+	return time.Time{}, nil
+}
+
+// apply applies the write, treating writes which are already in effect as applied.
+//
+// The runtime rejects a write with multiple relationships if any of them is already in effect,
+// so such writes are applied one relationship at a time to ensure the remaining relationships are written.
+func (o *Outbox) apply(ctx context.Context, write iamruntime.RelationshipWrite) error {
+	err := write.Apply(ctx)
+	if !inEffect(write, err) {
+		return err
+	}
+
+	if len(write.Relationships) <= 1 {
+		return nil
+	}
+
+	for _, relationship := range write.Relationships {
+		single := write
+		single.Relationships = []iamruntime.Relationship{relationship}
+
+		if err = single.Apply(ctx); err != nil && !inEffect(single, err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inEffect returns true if the write failed because its relationships already exist, or were already deleted.
+func inEffect(write iamruntime.RelationshipWrite, err error) bool {
+	switch write.Operation {
+	case iamruntime.RelationshipCreate:
+		return errors.Is(err, iamruntime.ErrRelationshipConflict)
+	case iamruntime.RelationshipDelete:
+		return errors.Is(err, iamruntime.ErrRelationshipNotFound)
+	default:
+		return false
+	}
+}
+
+// permanent returns true if retrying the write will not succeed.
+func permanent(err error) bool {
+	return errors.Is(err, iamruntime.ErrRelationshipInvalid) ||
+		errors.Is(err, iamruntime.ErrRelationshipPermissionDenied) ||
+		errors.Is(err, iamruntime.ErrRelationshipIDInvalid) ||
+		errors.Is(err, iamruntime.ErrRelationInvalid) ||
+		errors.Is(err, iamruntime.ErrRelationshipOperationInvalid)
+}
+
+func (o *Outbox) backoff(attempts int) time.Duration {
+	delay := o.minBackoff
+
+	for i := 1; i < attempts && delay < o.maxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, o.maxBackoff)
+}
+
+// Run replays pending writes until the context is canceled.
+// Writes are replayed when enqueued and when the backoff of a failed write elapses.
+// The context error is returned once canceled.
+func (o *Outbox) Run(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-o.notify:
+		case <-timer.C:
+		}
+
+		next, _ := o.replay(ctx)
+
+		timer.Stop()
+
+		if !next.IsZero() {
+			timer.Reset(max(next.Sub(o.now()), 0))
+		}
+	}
+}
+
+// Pending returns the entries which have not yet been applied, in order.
+func (o *Outbox) Pending(ctx context.Context) ([]Entry, error) {
+	return o.entries(ctx, StatePending)
+}
+
+// DeadLettered returns the entries which failed permanently or exceeded the maximum number of attempts, in order.
+func (o *Outbox) DeadLettered(ctx context.Context) ([]Entry, error) {
+	return o.entries(ctx, StateDeadLettered)
+}
+
+func (o *Outbox) entries(ctx context.Context, state State) ([]Entry, error) {
+	entries, err := o.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []Entry
+
+	for _, entry := range entries {
+		if entry.State == state {
+			matched = append(matched, entry)
+		}
+	}
+
+	return matched, nil
+}
+
+// Retry returns a dead lettered entry to pending so it is attempted again.
+// The entry keeps its original position, so it is attempted before any later pending writes.
+func (o *Outbox) Retry(ctx context.Context, key string) error {
+	o.replayMu.Lock()
+	defer o.replayMu.Unlock()
+
+	entry, ok, err := o.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if !ok || entry.State != StateDeadLettered {
+		return fmt.Errorf("%w: %s", iamruntime.ErrOutboxEntryNotFound, key)
+	}
+
+	now := o.now()
+
+	entry.State = StatePending
+	entry.Attempts = 0
+	entry.UpdatedAt = now
+	entry.NextAttempt = now
+
+	if err = o.store.Put(ctx, entry); err != nil {
+		return err
+	}
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Prune removes applied entries last updated before the provided time, returning the number removed.
+// Once removed, the idempotency key of an entry may be enqueued again.
+func (o *Outbox) Prune(ctx context.Context, before time.Time) (int, error) {
+	entries, err := o.entries(ctx, StateDone)
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int
+
+	for _, entry := range entries {
+		if !entry.UpdatedAt.Before(before) {
+			continue
+		}
+
+		if err = o.store.Delete(ctx, entry.Key); err != nil {
+			return removed, err
+		}
+
+		removed++
+	}
+
+	return removed, nil
+}
+
+// New creates a new [Outbox] applying writes from the store to the provided runtime.
+func New(runtime authorization.AuthorizationClient, store Store, opts ...Option) *Outbox {
+	outbox := &Outbox{
+		runtime:    runtime,
+		store:      store,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+		now:        time.Now,
+		notify:     make(chan struct{}, 1),
+	}
+
+	for _, opt := range opts {
+		opt(outbox)
+	}
+
+	return outbox
+}
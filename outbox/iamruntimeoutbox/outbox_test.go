@@ -0,0 +1,339 @@
+package iamruntimeoutbox
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+	"github.com/metal-toolbox/iam-runtime-contrib/mockruntime"
+)
+
+func newTestOutbox(runtime *mockruntime.MockRuntime, opts ...Option) (*Outbox, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	outbox := New(runtime, NewMemoryStore(), append([]Option{WithBackoff(time.Second, 4*time.Second)}, opts...)...)
+
+	outbox.now = func() time.Time { return now }
+
+	return outbox, &now
+}
+
+func TestOutboxReplay(t *testing.T) {
+	ctx := context.Background()
+
+	runtime := new(mockruntime.MockRuntime)
+
+	outbox, now := newTestOutbox(runtime)
+
+	require.NoError(t, outbox.EnqueueCreate(ctx, "create-abc123", iamruntime.Relate("testten-abc123").Parent("testten-root123")))
+	require.NoError(t, outbox.EnqueueDelete(ctx, "delete-abc123", iamruntime.Relate("testten-abc123").Owner("idntusr-owner1")))
+
+	// Enqueuing an existing key is ignored.
+	require.NoError(t, outbox.EnqueueCreate(ctx, "create-abc123", iamruntime.Relate("testten-abc123").Parent("testten-other")))
+
+	runtime.Mock.On("CreateRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root123"}}).
+		Return(status.Error(codes.Unavailable, "runtime unavailable")).Twice()
+
+	err := outbox.Replay(ctx)
+	assert.ErrorIs(t, err, iamruntime.ErrRelationshipRequestFailed, "expected replay error")
+
+	pending, err := outbox.Pending(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 2, "expected both writes to be pending")
+	assert.Equal(t, 1, pending[0].Attempts, "unexpected attempts")
+	assert.Equal(t, now.Add(time.Second), pending[0].NextAttempt, "unexpected next attempt")
+	assert.Equal(t, 0, pending[1].Attempts, "expected later write to wait for earlier write")
+
+	// Writes are not attempted until the backoff elapses.
+	require.NoError(t, outbox.Replay(ctx))
+
+	*now = now.Add(time.Second)
+
+	assert.Error(t, outbox.Replay(ctx), "expected second attempt to fail")
+
+	pending, err = outbox.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(2*time.Second), pending[0].NextAttempt, "expected backoff to double")
+
+	*now = now.Add(2 * time.Second)
+
+	runtime.Mock.On("CreateRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root123"}}).Return(nil).Once()
+	runtime.Mock.On("DeleteRelationships", "testten-abc123", map[string][]string{"owner": {"idntusr-owner1"}}).
+		Return(status.Error(codes.NotFound, "not found")).Once()
+
+	require.NoError(t, outbox.Replay(ctx))
+
+	pending, err = outbox.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "expected all writes to be applied")
+
+	// Applied keys are not enqueued again.
+	require.NoError(t, outbox.EnqueueCreate(ctx, "create-abc123", iamruntime.Relate("testten-abc123").Parent("testten-root123")))
+	require.NoError(t, outbox.Replay(ctx))
+
+	runtime.Mock.AssertExpectations(t)
+}
+
+func TestOutboxReplayPartiallyApplied(t *testing.T) {
+	testCases := []struct {
+		name    string
+		method  string
+		enqueue func(ctx context.Context, outbox *Outbox) error
+		err     error
+	}{
+		{
+			"create conflict",
+			"CreateRelationships",
+			func(ctx context.Context, outbox *Outbox) error {
+				return outbox.EnqueueCreate(ctx, "write", iamruntime.Relate("testten-abc123").Parent("testten-root123").Owner("idntusr-owner1"))
+			},
+			status.Error(codes.AlreadyExists, "already exists"),
+		},
+		{
+			"delete not found",
+			"DeleteRelationships",
+			func(ctx context.Context, outbox *Outbox) error {
+				return outbox.EnqueueDelete(ctx, "write", iamruntime.Relate("testten-abc123").Parent("testten-root123").Owner("idntusr-owner1"))
+			},
+			status.Error(codes.NotFound, "not found"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			runtime := new(mockruntime.MockRuntime)
+
+			outbox, _ := newTestOutbox(runtime)
+
+			require.NoError(t, tc.enqueue(ctx, outbox))
+
+			// The runtime rejects the whole write as one relationship is already in effect.
+			runtime.Mock.On(tc.method, "testten-abc123", map[string][]string{"parent": {"testten-root123"}, "owner": {"idntusr-owner1"}}).Return(tc.err).Once()
+			runtime.Mock.On(tc.method, "testten-abc123", map[string][]string{"parent": {"testten-root123"}}).Return(tc.err).Once()
+			runtime.Mock.On(tc.method, "testten-abc123", map[string][]string{"owner": {"idntusr-owner1"}}).Return(nil).Once()
+
+			require.NoError(t, outbox.Replay(ctx))
+
+			pending, err := outbox.Pending(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, pending, "expected write to be applied")
+
+			runtime.Mock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOutboxDeadLetter(t *testing.T) {
+	testCases := []struct {
+		name        string
+		maxAttempts int
+		err         error
+		attempts    int
+	}{
+		{
+			"invalid",
+			0,
+			status.Error(codes.InvalidArgument, "invalid"),
+			1,
+		},
+		{
+			"permission denied",
+			0,
+			status.Error(codes.PermissionDenied, "denied"),
+			1,
+		},
+		{
+			"max attempts",
+			2,
+			status.Error(codes.Unavailable, "unavailable"),
+			2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			runtime := new(mockruntime.MockRuntime)
+
+			outbox, now := newTestOutbox(runtime, WithMaxAttempts(tc.maxAttempts))
+
+			require.NoError(t, outbox.EnqueueCreate(ctx, "first", iamruntime.Relate("testten-abc123").Parent("testten-root123")))
+			require.NoError(t, outbox.EnqueueCreate(ctx, "second", iamruntime.Relate("testten-def456").Parent("testten-root123")))
+
+			runtime.Mock.On("CreateRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root123"}}).Return(tc.err).Times(tc.attempts)
+			runtime.Mock.On("CreateRelationships", "testten-def456", map[string][]string{"parent": {"testten-root123"}}).Return(nil).Once()
+
+			for range tc.attempts {
+				_ = outbox.Replay(ctx)
+
+				*now = now.Add(time.Minute)
+			}
+
+			deadLettered, err := outbox.DeadLettered(ctx)
+			require.NoError(t, err)
+			require.Len(t, deadLettered, 1, "expected write to be dead lettered")
+			assert.Equal(t, "first", deadLettered[0].Key, "unexpected dead lettered entry")
+			assert.Equal(t, tc.attempts, deadLettered[0].Attempts, "unexpected attempts")
+			assert.NotEmpty(t, deadLettered[0].LastError, "expected last error")
+
+			pending, err := outbox.Pending(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, pending, "expected later write to be applied")
+
+			runtime.Mock.On("CreateRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root123"}}).Return(nil).Once()
+
+			require.NoError(t, outbox.Retry(ctx, "first"))
+			require.NoError(t, outbox.Replay(ctx))
+
+			deadLettered, err = outbox.DeadLettered(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, deadLettered, "expected retried write to be applied")
+
+			assert.ErrorIs(t, outbox.Retry(ctx, "first"), iamruntime.ErrOutboxEntryNotFound, "expected applied entry not to be retried")
+
+			runtime.Mock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOutboxRetryUntilApplied(t *testing.T) {
+	ctx := context.Background()
+
+	runtime := new(mockruntime.MockRuntime)
+
+	outbox, now := newTestOutbox(runtime)
+
+	require.NoError(t, outbox.EnqueueCreate(ctx, "first", iamruntime.Relate("testten-abc123").Parent("testten-root123")))
+	require.NoError(t, outbox.EnqueueDelete(ctx, "second", iamruntime.Relate("testten-abc123").Parent("testten-root123")))
+
+	runtime.Mock.On("CreateRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root123"}}).Return(status.Error(codes.Unavailable, "unavailable")).Times(50)
+
+	for range 50 {
+		assert.ErrorIs(t, outbox.Replay(ctx), iamruntime.ErrRelationshipRequestFailed, "expected retryable error")
+
+		*now = now.Add(time.Minute)
+	}
+
+	deadLettered, err := outbox.DeadLettered(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, deadLettered, "expected retryable write not to be dead lettered by default")
+
+	pending, err := outbox.Pending(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 2, "expected later write to remain blocked")
+	assert.Equal(t, 50, pending[0].Attempts, "unexpected attempts")
+
+	runtime.Mock.AssertExpectations(t)
+}
+
+func TestOutboxPrune(t *testing.T) {
+	ctx := context.Background()
+
+	runtime := new(mockruntime.MockRuntime)
+
+	outbox, now := newTestOutbox(runtime)
+
+	runtime.Mock.On("CreateRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root123"}}).Return(nil).Twice()
+
+	require.NoError(t, outbox.EnqueueCreate(ctx, "create-abc123", iamruntime.Relate("testten-abc123").Parent("testten-root123")))
+	require.NoError(t, outbox.Replay(ctx))
+
+	removed, err := outbox.Prune(ctx, *now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed, "expected recent entries to be kept")
+
+	removed, err = outbox.Prune(ctx, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed, "expected applied entry to be removed")
+
+	// Pruned keys may be enqueued again.
+	require.NoError(t, outbox.EnqueueCreate(ctx, "create-abc123", iamruntime.Relate("testten-abc123").Parent("testten-root123")))
+	require.NoError(t, outbox.Replay(ctx))
+
+	runtime.Mock.AssertExpectations(t)
+}
+
+func TestOutboxRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runtime := new(mockruntime.MockRuntime)
+
+	runtime.Mock.On("CreateRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root123"}}).
+		Return(status.Error(codes.Unavailable, "unavailable")).Once()
+	runtime.Mock.On("CreateRelationships", "testten-abc123", map[string][]string{"parent": {"testten-root123"}}).Return(nil).Once()
+
+	outbox := New(runtime, NewMemoryStore(), WithBackoff(time.Millisecond, time.Millisecond))
+
+	done := make(chan error)
+
+	go func() {
+		done <- outbox.Run(ctx)
+	}()
+
+	require.NoError(t, outbox.EnqueueCreate(ctx, "create-abc123", iamruntime.Relate("testten-abc123").Parent("testten-root123")))
+
+	assert.Eventually(t, func() bool {
+		pending, err := outbox.Pending(ctx)
+
+		return err == nil && len(pending) == 0
+	}, time.Second, time.Millisecond, "expected write to be applied")
+
+	cancel()
+
+	assert.ErrorIs(t, <-done, context.Canceled, "expected run to return context error")
+
+	runtime.Mock.AssertExpectations(t)
+}
+
+func TestOutboxEnqueueInvalid(t *testing.T) {
+	ctx := context.Background()
+
+	outbox, _ := newTestOutbox(new(mockruntime.MockRuntime))
+
+	err := outbox.EnqueueCreate(ctx, "", iamruntime.Relate("testten-abc123").Parent("testten-root123"))
+	assert.ErrorIs(t, err, iamruntime.ErrOutboxStoreFailed, "expected empty key to be rejected")
+
+	err = outbox.EnqueueCreate(ctx, "create-abc123", iamruntime.Relate("abc123").Parent("testten-root123"))
+	assert.ErrorIs(t, err, iamruntime.ErrRelationshipIDInvalid, "expected invalid id to be rejected")
+
+	pending, err := outbox.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "expected invalid writes not to be enqueued")
+}
+
+func ExampleOutbox() {
+	ctx := context.TODO()
+
+	runtime, err := iamruntime.NewClient("/tmp/runtime.sock")
+	if err != nil {
+		panic(err)
+	}
+
+	store, err := OpenFileStore(ctx, "/var/lib/app/relationships.outbox")
+	if err != nil {
+		panic(err)
+	}
+
+	defer store.Close()
+
+	outbox := New(runtime, store)
+
+	go outbox.Run(ctx) //nolint:errcheck // runs until the context is canceled.
+
+	// Use a key derived from the request so retried requests do not enqueue the write twice.
+	err = outbox.EnqueueCreate(ctx, "loadbal-abc123:create", iamruntime.Relate("loadbal-abc123").Parent("tnntten-root123"))
+	if err != nil {
+		fmt.Println("failed to enqueue relationships:", err)
+	}
+}
@@ -0,0 +1,141 @@
+package iamruntimeoutbox
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+// State is the delivery state of an outbox entry.
+type State string
+
+const (
+	// StatePending entries have not yet been applied.
+	StatePending State = "pending"
+
+	// StateDone entries have been applied.
+	StateDone State = "done"
+
+	// StateDeadLettered entries failed permanently or exceeded the maximum number of attempts.
+	StateDeadLettered State = "dead_lettered"
+)
+
+// Entry is a relationship write persisted in the outbox.
+type Entry struct {
+	// Key is the idempotency key of the entry.
+	Key string `json:"key"`
+
+	// Write is the relationship write to apply.
+	Write iamruntime.RelationshipWrite `json:"write"`
+
+	// State is the delivery state of the entry.
+	State State `json:"state"`
+
+	// Attempts is the number of times applying the write has been attempted.
+	Attempts int `json:"attempts"`
+
+	// LastError is the error of the most recent failed attempt.
+	LastError string `json:"last_error,omitempty"`
+
+	// CreatedAt is when the entry was added to the outbox.
+	CreatedAt time.Time `json:"created_at"`
+
+	// UpdatedAt is when the entry was last changed.
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// NextAttempt is the earliest time the write will be attempted again.
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+// Store persists outbox entries.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the entry with the provided key.
+	// False is returned if the entry does not exist.
+	Get(ctx context.Context, key string) (Entry, bool, error)
+
+	// Put adds the entry or replaces the existing entry with the same key.
+	// Replaced entries keep their original position.
+	Put(ctx context.Context, entry Entry) error
+
+	// Delete removes the entry with the provided key.
+	// Deleting an entry which does not exist is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// List returns all entries in the order they were first added.
+	List(ctx context.Context) ([]Entry, error)
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore is an in-memory [Store].
+// Entries are lost when the process exits, so it is intended for tests and as a reference implementation.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	keys    []string
+	entries map[string]Entry
+}
+
+// Get returns the entry with the provided key.
+func (s *MemoryStore) Get(_ context.Context, key string) (Entry, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.entries[key]
+
+	return entry, ok, nil
+}
+
+// Put adds or replaces the entry.
+func (s *MemoryStore) Put(_ context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[entry.Key]; !ok {
+		s.keys = append(s.keys, entry.Key)
+	}
+
+	s.entries[entry.Key] = entry
+
+	return nil
+}
+
+// Delete removes the entry with the provided key.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key]; !ok {
+		return nil
+	}
+
+	delete(s.entries, key)
+
+	s.keys = slices.DeleteFunc(s.keys, func(k string) bool { return k == key })
+
+	return nil
+}
+
+// List returns all entries in the order they were first added.
+func (s *MemoryStore) List(_ context.Context) ([]Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]Entry, len(s.keys))
+
+	for i, key := range s.keys {
+		entries[i] = s.entries[key]
+	}
+
+	return entries, nil
+}
+
+// NewMemoryStore returns a new empty [MemoryStore].
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]Entry),
+	}
+}
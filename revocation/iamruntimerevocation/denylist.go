@@ -0,0 +1,99 @@
+// Package iamruntimerevocation implements iamruntime.RevocationChecker denylists
+// keyed by token id (jti), subject or credential hash.
+package iamruntimerevocation
+
+import (
+	"context"
+	"sync"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+var _ iamruntime.RevocationChecker = (*Denylist)(nil)
+
+// Denylist is an in-memory [iamruntime.RevocationChecker].
+//
+// Credentials are revoked if their token id (jti) or subject (sub) claims match a revoked value,
+// or if the hash of the raw credential matches a revoked hash.
+// Opaque credentials can only be revoked by hash.
+type Denylist struct {
+	mu       sync.RWMutex
+	tokenIDs map[string]struct{}
+	subjects map[string]struct{}
+	hashes   map[string]struct{}
+}
+
+// RevokeTokenID revokes all credentials with the provided token id (jti) claim.
+func (d *Denylist) RevokeTokenID(ids ...string) {
+	d.add(&d.tokenIDs, ids)
+}
+
+// RevokeSubject revokes all credentials with the provided subject (sub) claim.
+func (d *Denylist) RevokeSubject(subjects ...string) {
+	d.add(&d.subjects, subjects)
+}
+
+// RevokeCredential revokes the provided raw credentials.
+// Only the hash of the credential is stored.
+func (d *Denylist) RevokeCredential(raws ...string) {
+	hashes := make([]string, len(raws))
+
+	for i, raw := range raws {
+		hashes[i] = iamruntime.HashCredential(raw)
+	}
+
+	d.add(&d.hashes, hashes)
+}
+
+// RevokeHash revokes credentials matching the provided hashes.
+// Hashes must be generated with [iamruntime.HashCredential].
+func (d *Denylist) RevokeHash(hashes ...string) {
+	d.add(&d.hashes, hashes)
+}
+
+func (d *Denylist) add(set *map[string]struct{}, values []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if *set == nil {
+		*set = make(map[string]struct{}, len(values))
+	}
+
+	for _, value := range values {
+		(*set)[value] = struct{}{}
+	}
+}
+
+// IsRevoked returns true if the credential matches any revoked token id, subject or hash.
+func (d *Denylist) IsRevoked(_ context.Context, credential *iamruntime.Credential) (bool, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if _, ok := d.hashes[iamruntime.HashCredential(credential.Raw)]; ok {
+		return true, nil
+	}
+
+	if credential.IsOpaque() {
+		return false, nil
+	}
+
+	claims, err := iamruntime.CredentialClaims[iamruntime.StandardClaims](credential)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := d.subjects[claims.Subject]; ok && claims.Subject != "" {
+		return true, nil
+	}
+
+	if _, ok := d.tokenIDs[claims.ID]; ok && claims.ID != "" {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// NewDenylist creates a new empty in-memory denylist.
+func NewDenylist() *Denylist {
+	return &Denylist{}
+}
@@ -0,0 +1,76 @@
+package iamruntimerevocation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+func TestDenylist(t *testing.T) {
+	denylist := NewDenylist()
+
+	denylist.RevokeTokenID("revoked-jti")
+	denylist.RevokeSubject("idntusr-revoked")
+	denylist.RevokeCredential("revoked-api-key")
+
+	tokenCredential := func(claims jwt.MapClaims) *iamruntime.Credential {
+		raw, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		require.NoError(t, err)
+
+		token, _, err := jwt.NewParser().ParseUnverified(raw, jwt.MapClaims{})
+		require.NoError(t, err)
+
+		return iamruntime.NewTokenCredential(token)
+	}
+
+	testCases := []struct {
+		name          string
+		credential    *iamruntime.Credential
+		expectRevoked bool
+	}{
+		{
+			"valid token",
+			tokenCredential(jwt.MapClaims{"sub": "idntusr-abc123", "jti": "some-jti"}),
+			false,
+		},
+		{
+			"revoked token id",
+			tokenCredential(jwt.MapClaims{"sub": "idntusr-abc123", "jti": "revoked-jti"}),
+			true,
+		},
+		{
+			"revoked subject",
+			tokenCredential(jwt.MapClaims{"sub": "idntusr-revoked", "jti": "some-jti"}),
+			true,
+		},
+		{
+			"no claims",
+			tokenCredential(jwt.MapClaims{}),
+			false,
+		},
+		{
+			"valid opaque",
+			iamruntime.NewOpaqueCredential("some-api-key"),
+			false,
+		},
+		{
+			"revoked opaque",
+			iamruntime.NewOpaqueCredential("revoked-api-key"),
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			revoked, err := denylist.IsRevoked(context.Background(), tc.credential)
+			require.NoError(t, err, "unexpected error checking revocation")
+
+			assert.Equal(t, tc.expectRevoked, revoked, "unexpected revocation result")
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package iamruntimerevocation
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+const defaultReloadInterval = 10 * time.Second
+
+const (
+	// PrefixTokenID prefixes token id (jti) entries in a denylist file.
+	PrefixTokenID = "jti:"
+
+	// PrefixSubject prefixes subject (sub) entries in a denylist file.
+	PrefixSubject = "sub:"
+
+	// PrefixHash prefixes credential hash entries in a denylist file.
+	// Hashes must be generated with [iamruntime.HashCredential].
+	PrefixHash = "sha256:"
+)
+
+var _ iamruntime.RevocationChecker = (*FileDenylist)(nil)
+
+// FileDenylist is an [iamruntime.RevocationChecker] which loads its entries from a file.
+//
+// The file contains one entry per line, each prefixed with [PrefixTokenID], [PrefixSubject] or [PrefixHash].
+// Empty lines and lines starting with # are ignored.
+//
+//	# compromised service account
+//	sub:idntusr-abc123
+//	jti:0b8d6a5e-2f6c-4f4e-9c52-6b8e0f3c1f7a
+//	sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//
+// The file is checked for changes at most once per reload interval when credentials are checked.
+// If the file changes, it is reloaded. If a reload fails, the previously loaded entries continue to be used
+// and the error is available from [FileDenylist.Err].
+type FileDenylist struct {
+	path     string
+	interval time.Duration
+
+	mu        sync.Mutex
+	denylist  *Denylist
+	modTime   time.Time
+	size      int64
+	checkedAt time.Time
+	err       error
+}
+
+// IsRevoked reloads the file if it has changed, then checks the credential against the loaded entries.
+func (d *FileDenylist) IsRevoked(ctx context.Context, credential *iamruntime.Credential) (bool, error) {
+	return d.current().IsRevoked(ctx, credential)
+}
+
+// Err returns the error from the most recent reload, if any.
+func (d *FileDenylist) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.err
+}
+
+// Reload reloads the file if it has changed since it was last loaded.
+func (d *FileDenylist) Reload() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.err = d.reload()
+
+	return d.err
+}
+
+// current returns the loaded denylist, reloading the file first if the reload interval has elapsed.
+func (d *FileDenylist) current() *Denylist {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if time.Since(d.checkedAt) >= d.interval {
+		d.err = d.reload()
+	}
+
+	return d.denylist
+}
+
+func (d *FileDenylist) reload() error {
+	d.checkedAt = time.Now()
+
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return fmt.Errorf("%w: %w", iamruntime.ErrRevocationCheckFailed, err)
+	}
+
+	if d.denylist != nil && info.ModTime().Equal(d.modTime) && info.Size() == d.size {
+		return nil
+	}
+
+	denylist, err := loadDenylist(d.path)
+	if err != nil {
+		return err
+	}
+
+	d.denylist = denylist
+	d.modTime = info.ModTime()
+	d.size = info.Size()
+
+	return nil
+}
+
+func loadDenylist(path string) (*Denylist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", iamruntime.ErrRevocationCheckFailed, err)
+	}
+
+	defer file.Close()
+
+	denylist := NewDenylist()
+
+	scanner := bufio.NewScanner(file)
+
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case entry == "", strings.HasPrefix(entry, "#"):
+		case strings.HasPrefix(entry, PrefixTokenID):
+			denylist.RevokeTokenID(strings.TrimPrefix(entry, PrefixTokenID))
+		case strings.HasPrefix(entry, PrefixSubject):
+			denylist.RevokeSubject(strings.TrimPrefix(entry, PrefixSubject))
+		case strings.HasPrefix(entry, PrefixHash):
+			denylist.RevokeHash(strings.TrimPrefix(entry, PrefixHash))
+		default:
+			return nil, fmt.Errorf("%w: %s:%d: unknown entry type", iamruntime.ErrRevocationCheckFailed, path, line)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", iamruntime.ErrRevocationCheckFailed, err)
+	}
+
+	return denylist, nil
+}
+
+// NewFileDenylist creates a new [FileDenylist] loading the entries from the provided path.
+// The file is checked for changes at most once per reload interval.
+// If the reload interval is 0 or less, the default of 10 seconds is used.
+func NewFileDenylist(path string, reloadInterval time.Duration) (*FileDenylist, error) {
+	if reloadInterval <= 0 {
+		reloadInterval = defaultReloadInterval
+	}
+
+	denylist := &FileDenylist{
+		path:     path,
+		interval: reloadInterval,
+	}
+
+	if err := denylist.Reload(); err != nil {
+		return nil, err
+	}
+
+	return denylist, nil
+}
@@ -0,0 +1,64 @@
+package iamruntimerevocation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/iam-runtime-contrib/iamruntime"
+)
+
+func TestFileDenylist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist")
+
+	writeDenylist := func(content string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	now := time.Now()
+
+	writeDenylist("# revoked keys\nsha256:"+iamruntime.HashCredential("first-api-key")+"\n", now.Add(-time.Minute))
+
+	denylist, err := NewFileDenylist(path, time.Nanosecond)
+	require.NoError(t, err, "unexpected error loading denylist")
+
+	ctx := context.Background()
+
+	revoked, err := denylist.IsRevoked(ctx, iamruntime.NewOpaqueCredential("first-api-key"))
+	require.NoError(t, err)
+	assert.True(t, revoked, "expected first key to be revoked")
+
+	revoked, err = denylist.IsRevoked(ctx, iamruntime.NewOpaqueCredential("second-api-key"))
+	require.NoError(t, err)
+	assert.False(t, revoked, "expected second key to not be revoked")
+
+	writeDenylist("sha256:"+iamruntime.HashCredential("second-api-key")+"\n", now)
+
+	revoked, err = denylist.IsRevoked(ctx, iamruntime.NewOpaqueCredential("first-api-key"))
+	require.NoError(t, err)
+	assert.False(t, revoked, "expected first key to not be revoked after reload")
+
+	revoked, err = denylist.IsRevoked(ctx, iamruntime.NewOpaqueCredential("second-api-key"))
+	require.NoError(t, err)
+	assert.True(t, revoked, "expected second key to be revoked after reload")
+
+	writeDenylist("unknown:entry\n", now.Add(time.Minute))
+
+	revoked, err = denylist.IsRevoked(ctx, iamruntime.NewOpaqueCredential("second-api-key"))
+	require.NoError(t, err)
+	assert.True(t, revoked, "expected previous entries to be used after failed reload")
+
+	assert.ErrorIs(t, denylist.Err(), iamruntime.ErrRevocationCheckFailed, "expected reload error")
+}
+
+func TestNewFileDenylistMissing(t *testing.T) {
+	_, err := NewFileDenylist(filepath.Join(t.TempDir(), "missing"), 0)
+
+	assert.ErrorIs(t, err, iamruntime.ErrRevocationCheckFailed, "unexpected error returned")
+}